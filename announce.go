@@ -0,0 +1,290 @@
+package sonos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/caglar10ur/sonos/didl"
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+const (
+	// Bounds waiting for the clip to start playing
+	announceStartTimeout = 10 * time.Second
+	// Bounds restoring the rooms, which happens even when the announcement was cancelled
+	announceRestoreTimeout = 30 * time.Second
+	// Seconds requested for the subscription observing the clip, it isn't renewed
+	announceSubscriptionTimeout = 3600
+)
+
+// Announce plays the clip in the rooms at the volume and waits for it to finish. The rooms are
+// grouped with the first one for the announcement and restored afterwards, even when ctx is
+// done: their grouping, source, queue position, track offset, volume and play state.
+// Announcements wait for the one in progress, if any.
+//
+// The end of the clip is observed through the events of an AVTransport subscription to the
+// first room, which is polled if subscribing fails. HTTP clips are played as files.
+func (s *Sonos) Announce(ctx context.Context, rooms []*ZonePlayer, clipURI string, volume int) (err error) {
+	if len(rooms) == 0 {
+		return errors.New("no rooms to announce in")
+	}
+	if volume < 0 || volume > 100 {
+		return fmt.Errorf("volume %d out of range (0-100)", volume)
+	}
+	kind := DetectURIKind(clipURI)
+	switch kind {
+	case URIKindUnknown:
+		return fmt.Errorf("unsupported URI %q", clipURI)
+	case URIKindStream:
+		// Unlike radio streams, clips end
+		kind = URIKindFile
+	}
+
+	select {
+	case s.announcing <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.announcing }()
+
+	leader := rooms[0]
+	announced := make(map[string]*ZonePlayer)
+	for _, zp := range rooms {
+		announced[zp.UUID()] = zp
+	}
+
+	// Only the groups of the rooms are affected by the announcement
+	snap, err := leader.snapshot(func(group ZoneGroup) bool {
+		for _, member := range group.ZoneGroupMember {
+			if _, ok := announced[member.UUID]; ok {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	players := make(map[string]*ZonePlayer)
+	for _, group := range snap.Groups {
+		for _, member := range group.Members {
+			if zp, ok := announced[member.UUID]; ok {
+				players[member.UUID] = zp
+				continue
+			}
+			if players[member.UUID], err = leader.zonePlayerAt(member.UUID, member.Location); err != nil {
+				return fmt.Errorf("%s: %w", member.RoomName, err)
+			}
+		}
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), announceRestoreTimeout)
+		defer cancel()
+		if restoreErr := snap.restoreAnnouncement(ctx, players, announced); restoreErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to restore: %w", restoreErr))
+		}
+	}()
+
+	if !leader.subscribed(leader.AVTransport) {
+		opts := &SubscriptionOptions{ZonePlayer: leader, Service: leader.AVTransport, Timeout: announceSubscriptionTimeout}
+		if _, err := s.Subscribe(ctx, opts); err != nil {
+			s.logger.Warn("failed to subscribe for the announcement, polling", "room", leader.RoomName(), "error", err)
+		} else {
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+				defer cancel()
+				_ = s.Unsubscribe(ctx, opts)
+			}()
+		}
+	}
+
+	if err := leader.groupForAnnouncement(ctx, snap, rooms); err != nil {
+		return err
+	}
+	for _, group := range snap.Groups {
+		for _, member := range group.Members {
+			zp, ok := announced[member.UUID]
+			if !ok {
+				continue
+			}
+			if err := zp.SetVolume(volume); err != nil {
+				return fmt.Errorf("%s: %w", member.RoomName, err)
+			}
+			if member.Mute {
+				if err := zp.Unmute(); err != nil {
+					return fmt.Errorf("%s: %w", member.RoomName, err)
+				}
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	uri, err := leader.playClip(clipURI, kind)
+	if err != nil {
+		return err
+	}
+
+	// The state of the previous media, or its late STOPPED, would end the wait for the clip early
+	startCtx, cancel := context.WithTimeout(ctx, announceStartTimeout)
+	defer cancel()
+	if err := leader.WaitFor(startCtx, clipPlaying(uri)); err != nil {
+		return fmt.Errorf("clip didn't start: %w", err)
+	}
+	return leader.WaitFor(ctx, clipFinished())
+}
+
+// groupForAnnouncement makes the player the coordinator of a group of the rooms.
+func (z *ZonePlayer) groupForAnnouncement(ctx context.Context, snap *Snapshot, rooms []*ZonePlayer) error {
+	coordinators := make(map[string]string)
+	for _, group := range snap.Groups {
+		for _, member := range group.Members {
+			coordinators[member.UUID] = group.Coordinator
+		}
+	}
+	announced := make(map[string]bool)
+	for _, zp := range rooms {
+		announced[zp.UUID()] = true
+	}
+
+	// The player leaves its group unless it coordinates the rooms only
+	standalone := coordinators[z.UUID()] == z.UUID()
+	for uuid, coordinator := range coordinators {
+		if coordinator == z.UUID() && !announced[uuid] {
+			standalone = false
+		}
+	}
+	if !standalone {
+		if _, err := z.AVTransport.BecomeCoordinatorOfStandaloneGroup(&avt.BecomeCoordinatorOfStandaloneGroupArgs{InstanceID: 0}); err != nil {
+			return fmt.Errorf("%s: %w", z.RoomName(), err)
+		}
+	}
+
+	for _, zp := range rooms {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if zp.UUID() == z.UUID() || standalone && coordinators[zp.UUID()] == z.UUID() {
+			continue
+		}
+		if err := zp.SetAVTransportURI("x-rincon:" + z.UUID()); err != nil {
+			return fmt.Errorf("%s: %w", zp.RoomName(), err)
+		}
+	}
+	return nil
+}
+
+// playClip plays the clip as the media of the player, leaving its queue alone. It returns the
+// URI of the media as the player reports it.
+func (z *ZonePlayer) playClip(uri string, kind URIKind) (string, error) {
+	uri, item := uriItem(uri, kind, &PlayURIOptions{})
+	metadata, err := didl.Document(item)
+	if err != nil {
+		return "", err
+	}
+	if _, err := z.AVTransport.SetAVTransportURI(&avt.SetAVTransportURIArgs{InstanceID: 0, CurrentURI: uri, CurrentURIMetaData: metadata}); err != nil {
+		return "", err
+	}
+	return uri, z.Play()
+}
+
+// clipState is the transport state and media observed by clipPlaying.
+type clipState struct {
+	State avt.TransportStateEnum
+	URI   string
+}
+
+// clipPlaying holds once the transport plays the media with the given URI.
+func clipPlaying(uri string) Predicate {
+	// Events carry the state and the media separately
+	var mu sync.Mutex
+	var last clipState
+	return Predicate{
+		Name:    fmt.Sprintf("playing %s", uri),
+		Service: func(zp *ZonePlayer) SonosService { return zp.AVTransport },
+		Query: func(zp *ZonePlayer) (interface{}, error) {
+			info, err := zp.AVTransport.GetTransportInfo(&avt.GetTransportInfoArgs{InstanceID: 0})
+			if err != nil {
+				return nil, err
+			}
+			media, err := zp.AVTransport.GetMediaInfo(&avt.GetMediaInfoArgs{InstanceID: 0})
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			last = clipState{State: info.CurrentTransportState, URI: media.CurrentURI}
+			return last, nil
+		},
+		Observe: func(zp *ZonePlayer, evt interface{}) (interface{}, bool) {
+			state, hasState := observeAVTransport(evt, "TransportState")
+			media, hasMedia := observeAVTransport(evt, "AVTransportURI")
+			if !hasState && !hasMedia {
+				return nil, false
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if hasState {
+				last.State = avt.TransportStateEnum(state)
+			}
+			if hasMedia {
+				last.URI = media
+			}
+			return last, true
+		},
+		Match: func(v interface{}) bool {
+			return v == clipState{State: avt.TransportState_PLAYING, URI: uri}
+		},
+	}
+}
+
+// clipFinished holds once the transport stopped playing the clip, or was paused.
+func clipFinished() Predicate {
+	p := TransportStateIs(avt.TransportState_STOPPED)
+	p.Name = "clip finished"
+	p.Match = func(v interface{}) bool {
+		return v == avt.TransportState_STOPPED || v == avt.TransportState_PAUSED_PLAYBACK
+	}
+	return p
+}
+
+// restoreAnnouncement puts the rooms of an announcement back into the captured state. Groups
+// coordinated by other players kept playing, only their grouping is restored.
+func (s *Snapshot) restoreAnnouncement(ctx context.Context, players, announced map[string]*ZonePlayer) error {
+	var errs []error
+	if err := s.restoreGrouping(ctx, players); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, group := range s.Groups {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		for _, member := range group.Members {
+			if zp, ok := announced[member.UUID]; ok {
+				if err := zp.restoreVolume(&member); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", member.RoomName, err))
+				}
+			}
+		}
+
+		coordinator, ok := announced[group.Coordinator]
+		if !ok {
+			continue
+		}
+		if err := coordinator.restoreTransport(&group.Transport); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", coordinator.RoomName(), err))
+		}
+		if err := coordinator.restoreState(group.Transport.State); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", coordinator.RoomName(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,284 @@
+package sonos
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// simPlayer is the state of a simulated player.
+type simPlayer struct {
+	uuid, room  string
+	device      *fakeDevice
+	coordinator string
+	uri         string
+	track       int
+	relTime     string
+	state       string
+	volume      int
+	mute        bool
+}
+
+// simHousehold simulates players sharing a topology, playing clips ending with .mp3 for a
+// moment and reporting their transport state to the subscriptions.
+type simHousehold struct {
+	mu      sync.Mutex
+	players []*simPlayer
+	// e.g. "start Kitchen 40 Office 40", "stop Kitchen"
+	clips []string
+}
+
+var simArg = regexp.MustCompile(`<(\w+)>([^<]*)</\w+>`)
+
+func newSimHousehold(t *testing.T, players ...*simPlayer) *simHousehold {
+	h := &simHousehold{players: players}
+	for _, p := range players {
+		handlers := make(map[string]func(*http.Request) (*http.Response, error))
+		for _, action := range []string{
+			"AVTransport#GetMediaInfo", "AVTransport#GetPositionInfo", "AVTransport#GetTransportInfo",
+			"AVTransport#GetTransportSettings", "AVTransport#GetCrossfadeMode", "AVTransport#SetAVTransportURI",
+			"AVTransport#BecomeCoordinatorOfStandaloneGroup", "AVTransport#Play", "AVTransport#Pause",
+			"AVTransport#Stop", "AVTransport#Seek", "AVTransport#SetPlayMode", "AVTransport#SetCrossfadeMode",
+			"RenderingControl#GetVolume", "RenderingControl#SetVolume", "RenderingControl#GetMute",
+			"RenderingControl#SetMute", "GroupRenderingControl#GetGroupVolume", "GroupRenderingControl#GetGroupMute",
+			"ZoneGroupTopology#GetZoneGroupState",
+		} {
+			service, name, _ := strings.Cut(action, "#")
+			handlers["urn:schemas-upnp-org:service:"+service+":1#"+name] = func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				args := make(map[string]string)
+				for _, m := range simArg.FindAllStringSubmatch(string(body), -1) {
+					args[m[1]] = unescapeXML(t, m[2])
+				}
+				return mockResponseHandler(service, name, h.handle(p, name, args))(req)
+			}
+		}
+		p.device = newFakeDevice(t, handlers)
+		p.device.description = mockDeviceDescriptionFor(p.room, p.uuid)
+	}
+	return h
+}
+
+// handle applies the action to the player and returns the inner XML of the response.
+func (h *simHousehold) handle(p *simPlayer, action string, args map[string]string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch action {
+	case "GetMediaInfo":
+		return "<NrTracks>1</NrTracks><CurrentURI>" + escapeXML(p.uri) + "</CurrentURI><CurrentURIMetaData></CurrentURIMetaData>"
+	case "GetPositionInfo":
+		return "<Track>" + strconv.Itoa(p.track) + "</Track><RelTime>" + p.relTime + "</RelTime>"
+	case "GetTransportInfo":
+		return "<CurrentTransportState>" + p.state + "</CurrentTransportState>"
+	case "GetTransportSettings":
+		return "<PlayMode>NORMAL</PlayMode>"
+	case "GetCrossfadeMode":
+		return "<CrossfadeMode>0</CrossfadeMode>"
+	case "GetVolume", "GetGroupVolume":
+		return "<CurrentVolume>" + strconv.Itoa(p.volume) + "</CurrentVolume>"
+	case "GetMute", "GetGroupMute":
+		if p.mute {
+			return "<CurrentMute>1</CurrentMute>"
+		}
+		return "<CurrentMute>0</CurrentMute>"
+	case "GetZoneGroupState":
+		var b strings.Builder
+		b.WriteString("<ZoneGroupState><ZoneGroups>")
+		for _, c := range h.players {
+			if c.coordinator != c.uuid {
+				continue
+			}
+			b.WriteString(`<ZoneGroup Coordinator="` + c.uuid + `" ID="` + c.uuid + `:1">`)
+			for _, m := range h.players {
+				if m.coordinator == c.uuid {
+					b.WriteString(`<ZoneGroupMember UUID="` + m.uuid + `" Location="` + m.device.URL() + `/xml/device_description.xml" ZoneName="` + m.room + `" />`)
+				}
+			}
+			b.WriteString("</ZoneGroup>")
+		}
+		b.WriteString("</ZoneGroups></ZoneGroupState>")
+		return "<ZoneGroupState>" + escapeXML(b.String()) + "</ZoneGroupState>"
+
+	case "SetAVTransportURI":
+		if uuid, ok := strings.CutPrefix(args["CurrentURI"], "x-rincon:"); ok {
+			p.coordinator = uuid
+		} else {
+			p.uri, p.track, p.relTime = args["CurrentURI"], 1, "0:00:00"
+			h.setState(p, "STOPPED")
+		}
+	case "BecomeCoordinatorOfStandaloneGroup":
+		p.coordinator = p.uuid
+	case "Seek":
+		if args["Unit"] == "TRACK_NR" {
+			p.track, _ = strconv.Atoi(args["Target"])
+		} else {
+			p.relTime = args["Target"]
+		}
+	case "Play":
+		h.setState(p, "PLAYING")
+		if strings.HasSuffix(p.uri, ".mp3") {
+			clip := "start"
+			for _, m := range h.players {
+				if m.coordinator == p.uuid {
+					clip += " " + m.room + " " + strconv.Itoa(m.volume)
+				}
+			}
+			h.clips = append(h.clips, clip)
+			time.AfterFunc(20*time.Millisecond, func() {
+				h.mu.Lock()
+				defer h.mu.Unlock()
+				h.clips = append(h.clips, "stop "+p.room)
+				h.setState(p, "STOPPED")
+			})
+		}
+	case "Pause":
+		h.setState(p, "PAUSED_PLAYBACK")
+	case "Stop":
+		h.setState(p, "STOPPED")
+	case "SetVolume":
+		p.volume, _ = strconv.Atoi(args["DesiredVolume"])
+	case "SetMute":
+		p.mute = args["DesiredMute"] == "true"
+	}
+	return ""
+}
+
+// setState changes the transport state and sends it to the subscriptions.
+func (h *simHousehold) setState(p *simPlayer, state string) {
+	if p.state == state {
+		return
+	}
+	p.state = state
+	event := lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="` + state + `"/></InstanceID></Event>`)
+	for _, sid := range p.device.Sids() {
+		// In order, as the subscriber could take a late event for the current state otherwise
+		_, _ = p.device.Notify(sid, event)
+	}
+}
+
+func (h *simHousehold) snapshot() ([]simPlayer, []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var players []simPlayer
+	for _, p := range h.players {
+		players = append(players, *p)
+	}
+	return players, append([]string(nil), h.clips...)
+}
+
+func TestAnnounce(t *testing.T) {
+	const (
+		kitchen = "RINCON_000E58CDCA4001400"
+		office  = "RINCON_BBB"
+		den     = "RINCON_CCC"
+	)
+	queue := "x-rincon-queue:" + kitchen + "#0"
+	h := newSimHousehold(t,
+		&simPlayer{uuid: kitchen, room: "Kitchen", coordinator: kitchen, uri: queue, track: 3, relTime: "0:01:23", state: "PLAYING", volume: 17},
+		&simPlayer{uuid: office, room: "Office", coordinator: den, state: "PLAYING", volume: 30, mute: true},
+		&simPlayer{uuid: den, room: "Den", coordinator: den, uri: "x-rincon-mp3radio://radio.example.com/live", track: 1, relTime: "0:10:00", state: "PLAYING", volume: 25},
+	)
+	before, _ := h.snapshot()
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	rooms := []*ZonePlayer{h.players[0].device.ZonePlayer(t), h.players[1].device.ZonePlayer(t)}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The second announcement waits for the first one
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.Announce(ctx, rooms, "http://example.com/chime.mp3", 40)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Announce failed: %v", err)
+		}
+	}
+
+	after, clips := h.snapshot()
+	want := []string{"start Kitchen 40 Office 40", "stop Kitchen", "start Kitchen 40 Office 40", "stop Kitchen"}
+	if strings.Join(clips, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected clips %q, got %q", want, clips)
+	}
+	for i := range before {
+		before[i].device, after[i].device = nil, nil
+		if before[i] != after[i] {
+			t.Errorf("Expected %s to be restored to\n%+v, got\n%+v", before[i].room, before[i], after[i])
+		}
+	}
+	if sids := h.players[0].device.Sids(); len(sids) != 0 {
+		t.Errorf("Expected the subscriptions to be removed, got %v", sids)
+	}
+}
+
+func TestAnnounceErrors(t *testing.T) {
+	s, err := NewSonos(WithoutEventListener(), WithAdvertisedAddress("127.0.0.1:1400"))
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	zp := NewMockZonePlayer(t, nil)
+	for name, announce := range map[string]func() error{
+		"NoRooms": func() error { return s.Announce(context.Background(), nil, "http://example.com/chime.mp3", 40) },
+		"Volume": func() error {
+			return s.Announce(context.Background(), []*ZonePlayer{zp}, "http://example.com/chime.mp3", 101)
+		},
+		"Unsupported": func() error { return s.Announce(context.Background(), []*ZonePlayer{zp}, "spotify:track:123", 40) },
+	} {
+		if err := announce(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestClipPlaying(t *testing.T) {
+	const clip = "http://example.com/chime.mp3"
+	zp := NewMockZonePlayer(t, nil)
+	p := clipPlaying(clip)
+
+	event := func(instance string) AVTransportLastChange {
+		var evt AVTransportLastChange
+		if err := xml.Unmarshal([]byte(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0">`+instance+`</InstanceID></Event>`), &evt); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		return evt
+	}
+	for _, step := range []struct {
+		instance string
+		want     bool
+	}{
+		// The previous media is still playing
+		{`<TransportState val="PLAYING"/><AVTransportURI val="x-rincon-queue:RINCON_000E58CDCA4001400#0"/>`, false},
+		// The late STOPPED of SetAVTransportURI
+		{`<TransportState val="STOPPED"/><AVTransportURI val="` + clip + `"/>`, false},
+		{`<TransportState val="TRANSITIONING"/>`, false},
+		{`<TransportState val="PLAYING"/>`, true},
+	} {
+		v, ok := p.Observe(zp, event(step.instance))
+		if !ok || p.Match(v) != step.want {
+			t.Errorf("%s: expected %t, got %+v", step.instance, step.want, v)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package sonos
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	dev "github.com/caglar10ur/sonos/services/DeviceProperties"
+)
+
+// BondingStage describes how far a bonding change has progressed.
+type BondingStage string
+
+const (
+	// The player accepted the change
+	BondingRequested BondingStage = "REQUESTED"
+	// The topology does not reflect the change yet
+	BondingPending BondingStage = "PENDING"
+	// The topology reflects the change
+	BondingCompleted BondingStage = "COMPLETED"
+)
+
+// BondingProgress is reported while a bonding change propagates through the topology.
+type BondingProgress struct {
+	Stage    BondingStage
+	Topology *ZoneGroupState
+}
+
+// BondingProgressFunc receives the progress of a bonding change, it may be nil.
+type BondingProgressFunc func(BondingProgress)
+
+// waitForTopology waits until done reports the change as visible in the topology or ctx expires.
+func (z *ZonePlayer) waitForTopology(ctx context.Context, done func(*ZoneGroupState) bool, progress BondingProgressFunc) error {
+	var mu sync.Mutex
+	var pending, completed bool
+	report := func(p BondingProgress) {
+		if progress != nil {
+			progress(p)
+		}
+	}
+	// check reports the progress the topology shows, events and queries may race
+	check := func(zoneGroupState *ZoneGroupState) *ZoneGroupState {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case completed:
+		case done(zoneGroupState):
+			completed = true
+			report(BondingProgress{Stage: BondingCompleted, Topology: zoneGroupState})
+		case !pending:
+			pending = true
+			report(BondingProgress{Stage: BondingPending, Topology: zoneGroupState})
+		}
+		return zoneGroupState
+	}
+
+	report(BondingProgress{Stage: BondingRequested})
+	return z.WaitFor(ctx, Predicate{
+		Name:    "topology change",
+		Service: func(zp *ZonePlayer) SonosService { return zp.ZoneGroupTopology },
+		Query: func(zp *ZonePlayer) (interface{}, error) {
+			zoneGroupState, err := zp.GetZoneGroupState()
+			if err != nil {
+				return nil, err
+			}
+			return check(zoneGroupState), nil
+		},
+		Observe: func(zp *ZonePlayer, evt interface{}) (interface{}, bool) {
+			if e, ok := evt.(ZoneGroupTopologyZoneGroupState); ok {
+				return check(&ZoneGroupState{ZoneGroups: e.ZoneGroups.ZoneGroup}), true
+			}
+			return nil, false
+		},
+		Match: func(v interface{}) bool {
+			return done(v.(*ZoneGroupState))
+		},
+	}, WithPollInterval(topologyPollInterval))
+}
+
+// StereoPair is a pair of players bonded as the left and right channels of a single room.
+type StereoPair struct {
+	RoomName string
+	Left     string
+	Right    string
+	// Subwoofer bonded to the pair, if any
+	Subwoofer  string
+	ChannelMap ChannelMap
+}
+
+// stereoPairFromChannelMap returns the stereo pair described by the channel map, if any.
+func stereoPairFromChannelMap(m ChannelMap) (StereoPair, bool) {
+	var pair StereoPair
+	for _, e := range m {
+		switch {
+		case e.Has(ChannelLeftFront) && !e.Has(ChannelRightFront):
+			pair.Left = e.UUID
+		case e.Has(ChannelRightFront) && !e.Has(ChannelLeftFront):
+			pair.Right = e.UUID
+		case e.Has(ChannelSubwoofer):
+			pair.Subwoofer = e.UUID
+		}
+	}
+	if pair.Left == "" || pair.Right == "" {
+		return StereoPair{}, false
+	}
+	pair.ChannelMap = m
+	return pair, true
+}
+
+// StereoPairs returns the stereo pairs of the household parsed from the ChannelMapSet of the players.
+func (z *ZonePlayer) StereoPairs() ([]StereoPair, error) {
+	zoneGroupState, err := z.GetZoneGroupState()
+	if err != nil {
+		return nil, err
+	}
+	return stereoPairs(zoneGroupState), nil
+}
+
+func stereoPairs(zoneGroupState *ZoneGroupState) []StereoPair {
+	var pairs []StereoPair
+	seen := make(map[string]bool)
+	for _, group := range zoneGroupState.ZoneGroups {
+		for _, member := range group.ZoneGroupMember {
+			if member.ChannelMapSet == "" {
+				continue
+			}
+			m, err := ParseChannelMap(member.ChannelMapSet)
+			if err != nil {
+				continue
+			}
+			pair, ok := stereoPairFromChannelMap(m)
+			if !ok || seen[pair.Left] {
+				continue
+			}
+			seen[pair.Left] = true
+			pair.RoomName = member.ZoneName
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// Model numbers which can be paired with each other besides identical ones: the Sonos One,
+// One (Gen 2) and One SL as well as the Play:5 (Gen 2) and the Five
+var stereoPairFamilies = [][]string{
+	{"S13", "S18", "S22"},
+	{"S6", "S24"},
+}
+
+// Model numbers which can't be stereo paired at all: the Sub, Boost, Bridge, Playbar, Playbase,
+// Beam, Beam (Gen 2), Arc and Ray
+var stereoPairUnsupported = []string{"Sub", "WD100", "ZB100", "S9", "S11", "S14", "S31", "S19", "S36"}
+
+// isModelNumber reports whether the player is any of the models with the given numbers.
+func isModelNumber(zp *ZonePlayer, numbers ...string) bool {
+	return slices.Contains(numbers, zp.ModelNumber())
+}
+
+// CanStereoPair returns an error if the two players can't be bonded as a stereo pair.
+func CanStereoPair(left, right *ZonePlayer) error {
+	if left.UUID() == right.UUID() {
+		return fmt.Errorf("can't pair %s with itself", left.RoomName())
+	}
+	for _, zp := range []*ZonePlayer{left, right} {
+		if isModelNumber(zp, stereoPairUnsupported...) {
+			return fmt.Errorf("%s does not support stereo pairs", zp.ModelName())
+		}
+	}
+	if left.ModelNumber() == right.ModelNumber() {
+		return nil
+	}
+	for _, family := range stereoPairFamilies {
+		if isModelNumber(left, family...) && isModelNumber(right, family...) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s and %s can't be paired", left.ModelName(), right.ModelName())
+}
+
+// CreateStereoPair bonds the ZonePlayer as the left and the given player as the right channel.
+// It returns once the topology reflects the new pair.
+func (z *ZonePlayer) CreateStereoPair(ctx context.Context, right *ZonePlayer, progress BondingProgressFunc) error {
+	if err := CanStereoPair(z, right); err != nil {
+		return err
+	}
+
+	m := ChannelMap{
+		{UUID: z.UUID(), Channels: []Channel{ChannelLeftFront, ChannelLeftFront}},
+		{UUID: right.UUID(), Channels: []Channel{ChannelRightFront, ChannelRightFront}},
+	}
+	if _, err := z.DeviceProperties.CreateStereoPair(&dev.CreateStereoPairArgs{ChannelMapSet: m.String()}); err != nil {
+		return err
+	}
+
+	return z.waitForTopology(ctx, func(zoneGroupState *ZoneGroupState) bool {
+		for _, pair := range stereoPairs(zoneGroupState) {
+			if pair.Left == z.UUID() && pair.Right == right.UUID() {
+				return true
+			}
+		}
+		return false
+	}, progress)
+}
+
+// SeparateStereoPair splits the stereo pair the ZonePlayer is part of.
+// It returns once the topology no longer reports the pair.
+func (z *ZonePlayer) SeparateStereoPair(ctx context.Context, progress BondingProgressFunc) error {
+	zoneGroupState, err := z.GetZoneGroupState()
+	if err != nil {
+		return err
+	}
+
+	var pair *StereoPair
+	for _, p := range stereoPairs(zoneGroupState) {
+		if p.Left == z.UUID() || p.Right == z.UUID() {
+			pair = &p
+			break
+		}
+	}
+	if pair == nil {
+		return fmt.Errorf("%s is not part of a stereo pair", z.RoomName())
+	}
+
+	// The pair is controlled through its left player
+	left, err := z.zonePlayerAt(pair.Left, memberLocation(zoneGroupState, pair.Left))
+	if err != nil {
+		return err
+	}
+	if _, err := left.DeviceProperties.SeparateStereoPair(&dev.SeparateStereoPairArgs{ChannelMapSet: pair.ChannelMap.String()}); err != nil {
+		return err
+	}
+
+	return left.waitForTopology(ctx, func(zoneGroupState *ZoneGroupState) bool {
+		for _, p := range stereoPairs(zoneGroupState) {
+			if p.Left == pair.Left {
+				return false
+			}
+		}
+		return true
+	}, progress)
+}
+
+// memberLocation returns the location of the player with the given UUID.
+func memberLocation(zoneGroupState *ZoneGroupState, uuid string) string {
+	for _, group := range zoneGroupState.ZoneGroups {
+		for _, member := range group.ZoneGroupMember {
+			if member.UUID == uuid {
+				return member.Location
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package sonos
+
+import (
+	"bytes"
+	"context"
+	"html"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseChannelMap(t *testing.T) {
+	raw := "RINCON_A:LF,LF;RINCON_B:RF,RF;RINCON_C:SW,SW"
+
+	m, err := ParseChannelMap(raw)
+	if err != nil {
+		t.Fatalf("ParseChannelMap failed: %v", err)
+	}
+	if len(m) != 3 || !m.Entry("RINCON_B").Has(ChannelRightFront) {
+		t.Errorf("Unexpected channel map: %+v", m)
+	}
+	if m.String() != raw {
+		t.Errorf("Expected %s, got %s", raw, m.String())
+	}
+
+	pair, ok := stereoPairFromChannelMap(m)
+	if !ok || pair.Left != "RINCON_A" || pair.Right != "RINCON_B" || pair.Subwoofer != "RINCON_C" {
+		t.Errorf("Unexpected stereo pair: %+v", pair)
+	}
+
+	if _, err := ParseChannelMap("RINCON_A"); err == nil {
+		t.Error("Expected an error for a malformed channel map")
+	}
+}
+
+func TestCreateStereoPair(t *testing.T) {
+	before := `&lt;ZoneGroupState&gt;&lt;ZoneGroups&gt;&lt;ZoneGroup Coordinator=&quot;RINCON_000E58CDCA4001400&quot; ID=&quot;RINCON_000E58CDCA4001400:1&quot;&gt;&lt;ZoneGroupMember UUID=&quot;RINCON_000E58CDCA4001400&quot; Location=&quot;http://192.168.1.100:1400/xml/device_description.xml&quot; ZoneName=&quot;Kitchen&quot; /&gt;&lt;/ZoneGroup&gt;&lt;ZoneGroup Coordinator=&quot;RINCON_BBB&quot; ID=&quot;RINCON_BBB:1&quot;&gt;&lt;ZoneGroupMember UUID=&quot;RINCON_BBB&quot; Location=&quot;http://192.168.1.101:1400/xml/device_description.xml&quot; ZoneName=&quot;Kitchen 2&quot; /&gt;&lt;/ZoneGroup&gt;&lt;/ZoneGroups&gt;&lt;/ZoneGroupState&gt;`
+	after := `&lt;ZoneGroupState&gt;&lt;ZoneGroups&gt;&lt;ZoneGroup Coordinator=&quot;RINCON_000E58CDCA4001400&quot; ID=&quot;RINCON_000E58CDCA4001400:1&quot;&gt;&lt;ZoneGroupMember UUID=&quot;RINCON_000E58CDCA4001400&quot; Location=&quot;http://192.168.1.100:1400/xml/device_description.xml&quot; ZoneName=&quot;Kitchen&quot; ChannelMapSet=&quot;RINCON_000E58CDCA4001400:LF,LF;RINCON_BBB:RF,RF&quot; /&gt;&lt;ZoneGroupMember UUID=&quot;RINCON_BBB&quot; Location=&quot;http://192.168.1.101:1400/xml/device_description.xml&quot; ZoneName=&quot;Kitchen&quot; ChannelMapSet=&quot;RINCON_000E58CDCA4001400:LF,LF;RINCON_BBB:RF,RF&quot; Invisible=&quot;1&quot; /&gt;&lt;/ZoneGroup&gt;&lt;/ZoneGroups&gt;&lt;/ZoneGroupState&gt;`
+
+	var paired bool
+	polls := 0
+	handlers := map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:ZoneGroupTopology:1#GetZoneGroupState": func(req *http.Request) (*http.Response, error) {
+			state := before
+			// Let the topology lag behind the request once
+			if paired && polls > 0 {
+				state = after
+			}
+			if paired {
+				polls++
+			}
+			return mockResponseHandler("ZoneGroupTopology", "GetZoneGroupState", "<ZoneGroupState>"+state+"</ZoneGroupState>")(req)
+		},
+		"urn:schemas-upnp-org:service:DeviceProperties:1#CreateStereoPair": func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			if !bytes.Contains(body, []byte("<ChannelMapSet>RINCON_000E58CDCA4001400:LF,LF;RINCON_BBB:RF,RF</ChannelMapSet>")) {
+				t.Errorf("Unexpected CreateStereoPair request: %s", string(body))
+			}
+			paired = true
+			return mockSuccessHandler("DeviceProperties", "CreateStereoPair")(req)
+		},
+	}
+
+	zp := NewMockZonePlayer(t, handlers)
+	mock := zp.Client().Transport.(*MockRoundTripper)
+	mock.Descriptions = map[string]string{
+		"192.168.1.101:1400": mockDeviceDescriptionFor("Kitchen 2", "RINCON_BBB"),
+		"192.168.1.102:1400": withModel(mockDeviceDescriptionFor("Living Room", "RINCON_CCC"), "Sonos Beam", "S14"),
+	}
+
+	right, err := zp.zonePlayerAt("RINCON_BBB", "http://192.168.1.101:1400/xml/device_description.xml")
+	if err != nil {
+		t.Fatalf("Failed to create right player: %v", err)
+	}
+	beam, err := zp.zonePlayerAt("RINCON_CCC", "http://192.168.1.102:1400/xml/device_description.xml")
+	if err != nil {
+		t.Fatalf("Failed to create beam player: %v", err)
+	}
+
+	if err := zp.CreateStereoPair(context.Background(), beam, nil); err == nil {
+		t.Error("Expected pairing a Sonos One with a Sonos Beam to fail")
+	}
+
+	var stages []BondingStage
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = zp.CreateStereoPair(ctx, right, func(p BondingProgress) {
+		stages = append(stages, p.Stage)
+	})
+	if err != nil {
+		t.Fatalf("CreateStereoPair failed: %v", err)
+	}
+
+	expected := []BondingStage{BondingRequested, BondingPending, BondingCompleted}
+	if len(stages) != len(expected) {
+		t.Fatalf("Expected stages %v, got %v", expected, stages)
+	}
+	for i := range expected {
+		if stages[i] != expected[i] {
+			t.Errorf("Expected stages %v, got %v", expected, stages)
+		}
+	}
+
+	pairs, err := zp.StereoPairs()
+	if err != nil {
+		t.Fatalf("StereoPairs failed: %v", err)
+	}
+	if len(pairs) != 1 || pairs[0].RoomName != "Kitchen" || pairs[0].Left != "RINCON_000E58CDCA4001400" || pairs[0].Right != "RINCON_BBB" {
+		t.Errorf("Unexpected stereo pairs: %+v", pairs)
+	}
+}
+
+func TestCreateStereoPairEvents(t *testing.T) {
+	// Only the topology events report the pair
+	interval := topologyPollInterval
+	topologyPollInterval = time.Hour
+	defer func() { topologyPollInterval = interval }()
+
+	before := `<ZoneGroupState><ZoneGroups><ZoneGroup Coordinator="RINCON_000E58CDCA4001400" ID="RINCON_000E58CDCA4001400:1"><ZoneGroupMember UUID="RINCON_000E58CDCA4001400" ZoneName="Kitchen"/></ZoneGroup><ZoneGroup Coordinator="RINCON_BBB" ID="RINCON_BBB:1"><ZoneGroupMember UUID="RINCON_BBB" ZoneName="Kitchen 2"/></ZoneGroup></ZoneGroups></ZoneGroupState>`
+	after := `<ZoneGroupState><ZoneGroups><ZoneGroup Coordinator="RINCON_000E58CDCA4001400" ID="RINCON_000E58CDCA4001400:1"><ZoneGroupMember UUID="RINCON_000E58CDCA4001400" ZoneName="Kitchen" ChannelMapSet="RINCON_000E58CDCA4001400:LF,LF;RINCON_BBB:RF,RF"/><ZoneGroupMember UUID="RINCON_BBB" ZoneName="Kitchen" ChannelMapSet="RINCON_000E58CDCA4001400:LF,LF;RINCON_BBB:RF,RF" Invisible="1"/></ZoneGroup></ZoneGroups></ZoneGroupState>`
+
+	pending := make(chan struct{})
+	device := newFakeDevice(t, map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:ZoneGroupTopology:1#GetZoneGroupState": mockResponseHandler("ZoneGroupTopology", "GetZoneGroupState", "<ZoneGroupState>"+escapeXML(before)+"</ZoneGroupState>"),
+		"urn:schemas-upnp-org:service:DeviceProperties:1#CreateStereoPair":   mockSuccessHandler("DeviceProperties", "CreateStereoPair"),
+	})
+	zp := device.ZonePlayer(t)
+	other := newFakeDevice(t, nil)
+	other.description = mockDeviceDescriptionFor("Kitchen 2", "RINCON_BBB")
+	right := other.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sid, err := s.Subscribe(ctx, &SubscriptionOptions{ZonePlayer: zp, Service: zp.ZoneGroupTopology})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	// Keep reporting the pair until it was seen
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		<-pending
+		body := `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><ZoneGroupState>` + html.EscapeString(after) + `</ZoneGroupState></e:property></e:propertyset>`
+		for {
+			_, _ = device.Notify(sid, body)
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}()
+
+	var stages []BondingStage
+	if err := zp.CreateStereoPair(ctx, right, func(p BondingProgress) {
+		stages = append(stages, p.Stage)
+		if p.Stage == BondingPending {
+			close(pending)
+		}
+	}); err != nil {
+		t.Fatalf("CreateStereoPair failed: %v", err)
+	}
+	if len(stages) != 3 || stages[2] != BondingCompleted {
+		t.Errorf("Expected the pair to be completed, got %v", stages)
+	}
+}
@@ -0,0 +1,92 @@
+package sonos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Channel is a speaker channel as used by the ChannelMapSet and HTSatChanMapSet values.
+type Channel string
+
+const (
+	ChannelLeftFront  Channel = "LF"
+	ChannelRightFront Channel = "RF"
+	ChannelSubwoofer  Channel = "SW"
+	ChannelLeftRear   Channel = "LR"
+	ChannelRightRear  Channel = "RR"
+)
+
+// ChannelMapEntry assigns channels to a single player.
+type ChannelMapEntry struct {
+	UUID     string
+	Channels []Channel
+}
+
+// Has reports whether the entry carries the given channel.
+func (e ChannelMapEntry) Has(c Channel) bool {
+	for _, channel := range e.Channels {
+		if channel == c {
+			return true
+		}
+	}
+	return false
+}
+
+// ChannelMap is the parsed form of a channel map such as RINCON_A:LF,LF;RINCON_B:RF,RF.
+type ChannelMap []ChannelMapEntry
+
+// ParseChannelMap converts given raw string into ChannelMap or otherwise returns an error.
+func ParseChannelMap(raw string) (ChannelMap, error) {
+	var m ChannelMap
+	if raw == "" {
+		return m, nil
+	}
+
+	for _, entry := range strings.Split(raw, ";") {
+		uuid, channels, ok := strings.Cut(entry, ":")
+		if !ok || uuid == "" || channels == "" {
+			return nil, fmt.Errorf("failed to parse channel map %q", raw)
+		}
+
+		e := ChannelMapEntry{UUID: uuid}
+		for _, channel := range strings.Split(channels, ",") {
+			e.Channels = append(e.Channels, Channel(channel))
+		}
+		m = append(m, e)
+	}
+	return m, nil
+}
+
+// Entry returns the entry of the given player or nil.
+func (m ChannelMap) Entry(uuid string) *ChannelMapEntry {
+	for i := range m {
+		if m[i].UUID == uuid {
+			return &m[i]
+		}
+	}
+	return nil
+}
+
+// With returns the players carrying the given channel.
+func (m ChannelMap) With(c Channel) []string {
+	var uuids []string
+	for _, e := range m {
+		if e.Has(c) {
+			uuids = append(uuids, e.UUID)
+		}
+	}
+	return uuids
+}
+
+// String returns the raw form of the channel map.
+func (m ChannelMap) String() string {
+	entries := make([]string, 0, len(m))
+	for _, e := range m {
+		channels := make([]string, 0, len(e.Channels))
+		for _, channel := range e.Channels {
+			channels = append(channels, string(channel))
+		}
+		entries = append(entries, e.UUID+":"+strings.Join(channels, ","))
+	}
+	return strings.Join(entries, ";")
+}
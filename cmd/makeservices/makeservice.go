@@ -137,7 +137,8 @@ func MakeServiceApi(serviceName, serviceControlEndpoint, serviceEventEndpoint st
 	}
 
 	tpl, err := template.New("service").Funcs(template.FuncMap{
-		"lower": strings.ToLower,
+		"lower":     strings.ToLower,
+		"hasPrefix": strings.HasPrefix,
 		"sanitize": func(s string) string {
 			s = strings.ReplaceAll(s, "-", "_")
 			s = strings.ReplaceAll(s, ".", "_")
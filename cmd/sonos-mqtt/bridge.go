@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caglar10ur/sonos"
+	"github.com/caglar10ur/sonos/didl"
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Player is the part of a ZonePlayer the bridge controls.
+type Player interface {
+	RoomName() string
+	UUID() string
+	Play() error
+	Pause() error
+	Stop() error
+	Next() error
+	Previous() error
+	SetVolume(int) error
+	Mute() error
+	Unmute() error
+	// Join makes the player a member of the group coordinated by the player with the UUID
+	Join(uuid string) error
+	// Leave takes the player out of its group
+	Leave() error
+}
+
+// zonePlayer adapts a ZonePlayer to Player.
+type zonePlayer struct {
+	*sonos.ZonePlayer
+}
+
+func (z zonePlayer) Join(uuid string) error {
+	return z.SetAVTransportURI("x-rincon:" + uuid)
+}
+
+func (z zonePlayer) Leave() error {
+	_, err := z.AVTransport.BecomeCoordinatorOfStandaloneGroup(&avt.BecomeCoordinatorOfStandaloneGroupArgs{InstanceID: 0})
+	return err
+}
+
+// Transport is published to <prefix>/<room>/transport.
+type Transport struct {
+	State string `json:"state"`
+}
+
+// Track is published to <prefix>/<room>/track.
+type Track struct {
+	Number   int    `json:"number"`
+	URI      string `json:"uri"`
+	Title    string `json:"title,omitempty"`
+	Artist   string `json:"artist,omitempty"`
+	Album    string `json:"album,omitempty"`
+	Duration int    `json:"duration"`
+}
+
+// Volume is published to <prefix>/<room>/volume.
+type Volume struct {
+	Volume int `json:"volume"`
+}
+
+// Mute is published to <prefix>/<room>/mute.
+type Mute struct {
+	Mute bool `json:"mute"`
+}
+
+// Group is published to <prefix>/<room>/group.
+type Group struct {
+	ID          string   `json:"id"`
+	Coordinator string   `json:"coordinator"`
+	Members     []string `json:"members"`
+}
+
+// Bridge publishes the state of the players to MQTT and controls them from command topics.
+type Bridge struct {
+	client mqtt.Client
+	prefix string
+	logger *slog.Logger
+
+	mu sync.Mutex
+	// Players keyed by their topic name
+	players map[string]Player
+	tracks  map[string]Track
+}
+
+func NewBridge(client mqtt.Client, prefix string, logger *slog.Logger) *Bridge {
+	return &Bridge{
+		client:  client,
+		prefix:  strings.TrimSuffix(prefix, "/"),
+		logger:  logger,
+		players: make(map[string]Player),
+		tracks:  make(map[string]Track),
+	}
+}
+
+// Start subscribes to the command topics, <prefix>/<room>/set/<command>.
+func (b *Bridge) Start() error {
+	token := b.client.Subscribe(b.prefix+"/+/set/+", 1, b.command)
+	token.Wait()
+	return token.Error()
+}
+
+// AddPlayer makes the player controllable through the command topics. It returns false if it
+// was added already.
+func (b *Bridge) AddPlayer(p Player) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	room := topicName(p.RoomName())
+	if _, ok := b.players[room]; ok {
+		return false
+	}
+	b.players[room] = p
+	return true
+}
+
+// Handler returns the handler publishing the events of the player.
+func (b *Bridge) Handler(p Player) sonos.EventHandlerFunc {
+	room := topicName(p.RoomName())
+	return func(evt interface{}) {
+		b.event(room, evt)
+	}
+}
+
+func (b *Bridge) event(room string, evt interface{}) {
+	switch e := evt.(type) {
+	case sonos.AVTransportLastChange:
+		c := e.Typed()
+		if c.Has("TransportState") {
+			b.publish(room, "transport", Transport{State: string(c.TransportState)})
+		}
+		if c.Has("CurrentTrack") || c.Has("CurrentTrackURI") || c.Has("CurrentTrackDuration") || c.Has("CurrentTrackMetaData") {
+			b.mu.Lock()
+			track := b.tracks[room]
+			if c.Has("CurrentTrack") {
+				track.Number = c.CurrentTrack
+			}
+			if c.Has("CurrentTrackURI") {
+				track.URI = c.CurrentTrackURI
+			}
+			if c.Has("CurrentTrackDuration") {
+				track.Duration = int(c.CurrentTrackDuration / time.Second)
+			}
+			if c.Has("CurrentTrackMetaData") {
+				track.Title, track.Artist, track.Album = describe(c.CurrentTrackMetaData)
+			}
+			b.tracks[room] = track
+			b.mu.Unlock()
+			b.publish(room, "track", track)
+		}
+	case sonos.RenderingControlLastChange:
+		c := e.Typed()
+		if v, ok := c.Volume["Master"]; ok {
+			b.publish(room, "volume", Volume{Volume: v})
+		}
+		if m, ok := c.Mute["Master"]; ok {
+			b.publish(room, "mute", Mute{Mute: m})
+		}
+	case sonos.ZoneGroupTopologyZoneGroupState:
+		for _, group := range e.ZoneGroups.ZoneGroup {
+			g := Group{ID: group.ID}
+			members := group.VisibleMembers()
+			for _, member := range members {
+				g.Members = append(g.Members, member.ZoneName)
+				if member.UUID == group.Coordinator {
+					g.Coordinator = member.ZoneName
+				}
+			}
+			for _, member := range members {
+				b.publish(topicName(member.ZoneName), "group", g)
+			}
+		}
+	}
+}
+
+// publish publishes the state of the room as a retained message.
+func (b *Bridge) publish(room, topic string, v interface{}) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		b.logger.Error("failed to encode state", "room", room, "topic", topic, "error", err)
+		return
+	}
+	token := b.client.Publish(b.prefix+"/"+room+"/"+topic, 1, true, payload)
+	go func() {
+		if token.Wait() && token.Error() != nil {
+			b.logger.Warn("failed to publish state", "room", room, "topic", topic, "error", token.Error())
+		}
+	}()
+}
+
+func (b *Bridge) command(_ mqtt.Client, msg mqtt.Message) {
+	parts := strings.Split(strings.TrimPrefix(msg.Topic(), b.prefix+"/"), "/")
+	if len(parts) != 3 {
+		return
+	}
+	room, command, payload := parts[0], parts[2], strings.TrimSpace(string(msg.Payload()))
+
+	b.mu.Lock()
+	p, ok := b.players[room]
+	b.mu.Unlock()
+	if !ok {
+		b.logger.Warn("command for unknown room", "room", room, "command", command)
+		return
+	}
+
+	if err := b.run(p, command, payload); err != nil {
+		b.logger.Warn("command failed", "room", room, "command", command, "payload", payload, "error", err)
+	}
+}
+
+func (b *Bridge) run(p Player, command, payload string) error {
+	switch command {
+	case "play":
+		return p.Play()
+	case "pause":
+		return p.Pause()
+	case "stop":
+		return p.Stop()
+	case "next":
+		return p.Next()
+	case "previous":
+		return p.Previous()
+	case "volume":
+		volume, err := strconv.Atoi(payload)
+		if err != nil || volume < 0 || volume > 100 {
+			return fmt.Errorf("invalid volume %q", payload)
+		}
+		return p.SetVolume(volume)
+	case "mute":
+		mute, err := strconv.ParseBool(payload)
+		if err != nil {
+			return fmt.Errorf("invalid mute %q", payload)
+		}
+		if mute {
+			return p.Mute()
+		}
+		return p.Unmute()
+	case "join":
+		b.mu.Lock()
+		coordinator, ok := b.players[topicName(payload)]
+		b.mu.Unlock()
+		if !ok {
+			return fmt.Errorf("unknown room %q", payload)
+		}
+		return p.Join(coordinator.UUID())
+	case "leave":
+		return p.Leave()
+	}
+	return fmt.Errorf("unknown command")
+}
+
+// describe returns the title, artist and album of the track.
+func describe(item *didl.Item) (title, artist, album string) {
+	if item == nil {
+		return
+	}
+	return didl.First(item.Title), didl.First(item.Creator), didl.First(item.Album)
+}
+
+// topicName returns the room name usable as a topic level.
+func topicName(room string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(room)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caglar10ur/sonos"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	server "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+type fakePlayer struct {
+	room, uuid string
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (p *fakePlayer) RoomName() string { return p.room }
+func (p *fakePlayer) UUID() string     { return p.uuid }
+func (p *fakePlayer) Play() error      { return p.call("play") }
+func (p *fakePlayer) Pause() error     { return p.call("pause") }
+func (p *fakePlayer) Stop() error      { return p.call("stop") }
+func (p *fakePlayer) Next() error      { return p.call("next") }
+func (p *fakePlayer) Previous() error  { return p.call("previous") }
+func (p *fakePlayer) SetVolume(v int) error {
+	return p.call("volume " + strconv.Itoa(v))
+}
+func (p *fakePlayer) Mute() error            { return p.call("mute") }
+func (p *fakePlayer) Unmute() error          { return p.call("unmute") }
+func (p *fakePlayer) Join(uuid string) error { return p.call("join " + uuid) }
+func (p *fakePlayer) Leave() error           { return p.call("leave") }
+
+func (p *fakePlayer) call(c string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, c)
+	return nil
+}
+
+func (p *fakePlayer) Calls() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.calls)
+}
+
+// startBroker starts an in-process broker and returns its address.
+func startBroker(t *testing.T) string {
+	t.Helper()
+
+	s := server.New(&server.Options{InlineClient: true, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err := s.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatalf("AddHook failed: %v", err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	if err := s.AddListener(tcp); err != nil {
+		t.Fatalf("AddListener failed: %v", err)
+	}
+	go s.Serve()
+	t.Cleanup(func() { s.Close() })
+
+	return "tcp://" + tcp.Address()
+}
+
+func connect(t *testing.T, broker, id string) mqtt.Client {
+	t.Helper()
+
+	opts := mqtt.NewClientOptions().AddBroker(broker).SetClientID(id).SetProtocolVersion(4)
+	c := mqtt.NewClient(opts)
+	if token := c.Connect(); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("Connect failed: %v", token.Error())
+	}
+	t.Cleanup(func() { c.Disconnect(0) })
+	return c
+}
+
+// retained collects the messages published on the topics below the filter.
+func retained(t *testing.T, c mqtt.Client, filter string) func(topic string) string {
+	t.Helper()
+
+	var mu sync.Mutex
+	messages := make(map[string]string)
+	token := c.Subscribe(filter, 1, func(_ mqtt.Client, msg mqtt.Message) {
+		mu.Lock()
+		defer mu.Unlock()
+		messages[msg.Topic()] = string(msg.Payload())
+	})
+	if !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("Subscribe failed: %v", token.Error())
+	}
+
+	return func(topic string) string {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			m, ok := messages[topic]
+			mu.Unlock()
+			if ok {
+				return m
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("No message on %s", topic)
+		return ""
+	}
+}
+
+func TestBridgeState(t *testing.T) {
+	broker := startBroker(t)
+
+	b := NewBridge(connect(t, broker, "bridge"), "sonos", slog.Default())
+	kitchen := &fakePlayer{room: "Kitchen", uuid: "RINCON_1"}
+	b.AddPlayer(kitchen)
+	handler := b.Handler(kitchen)
+
+	var avt sonos.AVTransportLastChange
+	raw := `<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/><CurrentTrack val="3"/><CurrentTrackURI val="x-file-cifs://song.mp3"/><CurrentTrackDuration val="0:03:25"/><CurrentTrackMetaData val="&lt;DIDL-Lite xmlns:dc=&quot;http://purl.org/dc/elements/1.1/&quot; xmlns:upnp=&quot;urn:schemas-upnp-org:metadata-1-0/upnp/&quot; xmlns=&quot;urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/&quot;&gt;&lt;item id=&quot;-1&quot; parentID=&quot;-1&quot;&gt;&lt;dc:title&gt;Song&lt;/dc:title&gt;&lt;dc:creator&gt;Artist&lt;/dc:creator&gt;&lt;/item&gt;&lt;/DIDL-Lite&gt;"/></InstanceID></Event>`
+	if err := xml.Unmarshal([]byte(raw), &avt); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	handler(avt)
+
+	var rc sonos.RenderingControlLastChange
+	raw = `<Event xmlns="urn:schemas-upnp-org:metadata-1-0/RCS/"><InstanceID val="0"><Volume channel="Master" val="25"/><Mute channel="Master" val="0"/></InstanceID></Event>`
+	if err := xml.Unmarshal([]byte(raw), &rc); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	handler(rc)
+
+	var zgs sonos.ZoneGroupTopologyZoneGroupState
+	raw = `<ZoneGroupState><ZoneGroups><ZoneGroup Coordinator="RINCON_1" ID="RINCON_1:1"><ZoneGroupMember UUID="RINCON_1" ZoneName="Kitchen"/><ZoneGroupMember UUID="RINCON_2" ZoneName="Living Room"/></ZoneGroup></ZoneGroups></ZoneGroupState>`
+	if err := xml.Unmarshal([]byte(raw), &zgs); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	handler(zgs)
+
+	// State is retained, so a client connecting afterwards still gets it
+	get := retained(t, connect(t, broker, "observer"), "sonos/#")
+
+	for topic, want := range map[string]string{
+		"sonos/Kitchen/transport": `{"state":"PLAYING"}`,
+		"sonos/Kitchen/track":     `{"number":3,"uri":"x-file-cifs://song.mp3","title":"Song","artist":"Artist","duration":205}`,
+		"sonos/Kitchen/volume":    `{"volume":25}`,
+		"sonos/Kitchen/mute":      `{"mute":false}`,
+		"sonos/Kitchen/group":     `{"id":"RINCON_1:1","coordinator":"Kitchen","members":["Kitchen","Living Room"]}`,
+		"sonos/Living Room/group": `{"id":"RINCON_1:1","coordinator":"Kitchen","members":["Kitchen","Living Room"]}`,
+	} {
+		if got := get(topic); got != want {
+			t.Errorf("%s: expected %s, got %s", topic, want, got)
+		}
+	}
+}
+
+func TestBridgeCommands(t *testing.T) {
+	broker := startBroker(t)
+
+	b := NewBridge(connect(t, broker, "bridge"), "sonos", slog.Default())
+	kitchen := &fakePlayer{room: "Kitchen", uuid: "RINCON_1"}
+	living := &fakePlayer{room: "Living Room", uuid: "RINCON_2"}
+	b.AddPlayer(kitchen)
+	b.AddPlayer(living)
+	if err := b.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	c := connect(t, broker, "controller")
+	for _, cmd := range []struct{ topic, payload string }{
+		{"sonos/Kitchen/set/volume", "30"},
+		{"sonos/Kitchen/set/volume", "300"},
+		{"sonos/Kitchen/set/mute", "true"},
+		{"sonos/Kitchen/set/play", ""},
+		{"sonos/Living Room/set/join", "Kitchen"},
+		{"sonos/Living Room/set/leave", ""},
+		{"sonos/Attic/set/play", ""},
+	} {
+		if token := c.Publish(cmd.topic, 1, false, cmd.payload); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+			t.Fatalf("Publish failed: %v", token.Error())
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && (len(kitchen.Calls()) < 3 || len(living.Calls()) < 2) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if want := []string{"volume 30", "mute", "play"}; !slices.Equal(kitchen.Calls(), want) {
+		t.Errorf("Expected %v, got %v", want, kitchen.Calls())
+	}
+	if want := []string{"join RINCON_1", "leave"}; !slices.Equal(living.Calls(), want) {
+		t.Errorf("Expected %v, got %v", want, living.Calls())
+	}
+}
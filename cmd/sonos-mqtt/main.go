@@ -0,0 +1,120 @@
+// Command sonos-mqtt publishes the state of the Sonos players on the network to MQTT and
+// controls them from command topics.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/caglar10ur/sonos"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func main() {
+	broker := flag.String("broker", "tcp://localhost:1883", "MQTT broker URL")
+	clientID := flag.String("client-id", "sonos-mqtt", "MQTT client ID")
+	username := flag.String("username", os.Getenv("MQTT_USERNAME"), "MQTT username")
+	password := flag.String("password", os.Getenv("MQTT_PASSWORD"), "MQTT password")
+	prefix := flag.String("prefix", "sonos", "Topic prefix")
+	searchTimeout := flag.Duration("search-timeout", 3*time.Second, "Timeout for Sonos device search")
+	searchInterval := flag.Duration("search-interval", time.Minute, "Interval between Sonos device searches")
+
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	logger := slog.Default()
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(*broker).
+		SetClientID(*clientID).
+		SetUsername(*username).
+		SetPassword(*password).
+		SetProtocolVersion(4).
+		SetAutoReconnect(true).
+		SetWill(*prefix+"/bridge", "offline", 1, true)
+
+	var bridge *Bridge
+	// Command subscriptions don't survive a reconnect with a clean session
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		if err := bridge.Start(); err != nil {
+			logger.Error("failed to subscribe to commands", "error", err)
+		}
+		c.Publish(*prefix+"/bridge", 1, true, "online")
+	})
+
+	client := mqtt.NewClient(opts)
+	bridge = NewBridge(client, *prefix, logger)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		log.Fatalf("Connecting to %s failed: %v", *broker, token.Error())
+	}
+	defer client.Disconnect(250)
+
+	s, err := sonos.NewSonos(sonos.WithLogger(logger))
+	if err != nil {
+		log.Fatalf("Creating sonos failed: %v", err)
+	}
+	defer s.Close()
+
+	m := sonos.NewSubscriptionManager(s)
+	defer m.Close(context.Background())
+
+	topology := &topologySubscription{m: m, bridge: bridge, logger: logger}
+
+	add := func(zp *sonos.ZonePlayer) {
+		p := zonePlayer{zp}
+		if !bridge.AddPlayer(p) {
+			return
+		}
+		logger.Info("bridging", "room", zp.RoomName())
+		for _, service := range []sonos.SonosService{zp.AVTransport, zp.RenderingControl} {
+			if err := m.Subscribe(ctx, &sonos.SubscriptionOptions{ZonePlayer: zp, Service: service, EventHandler: bridge.Handler(p)}); err != nil {
+				logger.Error("failed to subscribe", "room", zp.RoomName(), "error", err)
+			}
+		}
+		topology.add(ctx, zp)
+	}
+
+	search := func() error {
+		// Search returns once the request is sent, players are reported until the timeout
+		searchCtx, cancel := context.WithTimeout(ctx, *searchTimeout)
+		time.AfterFunc(*searchTimeout, cancel)
+		return s.Search(searchCtx, func(s *sonos.Sonos, zp *sonos.ZonePlayer) {
+			// Search only reports coordinators
+			g, err := zp.Group()
+			if err != nil {
+				logger.Error("failed to look up group", "room", zp.RoomName(), "error", err)
+				add(zp)
+				return
+			}
+			for _, member := range g.Members {
+				add(member)
+			}
+		})
+	}
+	if err := search(); err != nil {
+		log.Fatalf("Searching for players failed: %v", err)
+	}
+
+	// Search again for players added to the network or powered on later
+	ticker := time.NewTicker(*searchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			topology.check(ctx)
+			if err := search(); err != nil {
+				logger.Warn("failed to search for players", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/caglar10ur/sonos"
+)
+
+// Consecutive failures after which the topology is followed through another player
+const topologyFailures = 3
+
+// topologySubscription keeps a single ZoneGroupTopology subscription, every player reports the
+// topology of the whole household. It moves to another player when the one it is on can't be
+// reached.
+type topologySubscription struct {
+	m      *sonos.SubscriptionManager
+	bridge *Bridge
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	players []*sonos.ZonePlayer
+	opts    *sonos.SubscriptionOptions
+}
+
+// add makes the player a candidate for the subscription and subscribes through it if there is
+// no subscription yet.
+func (t *topologySubscription) add(ctx context.Context, zp *sonos.ZonePlayer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.players = append(t.players, zp)
+	if t.opts == nil {
+		t.subscribe(ctx, nil)
+	}
+}
+
+// check moves the subscription to another player once the manager keeps failing to renew it.
+func (t *topologySubscription) check(ctx context.Context) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.opts == nil {
+		t.subscribe(ctx, nil)
+		return
+	}
+	for _, h := range t.m.Health() {
+		if h.Options != t.opts || h.State != sonos.SubscriptionRetrying || h.Failures < topologyFailures {
+			continue
+		}
+		lost := t.opts.ZonePlayer
+		t.logger.Warn("topology subscription keeps failing, moving it", "room", lost.RoomName(), "error", h.LastError)
+		// The player most likely dropped it already
+		_ = t.m.Unsubscribe(ctx, t.opts)
+		t.opts = nil
+		t.subscribe(ctx, lost)
+		return
+	}
+}
+
+// subscribe subscribes through the first player other than exclude which accepts it.
+func (t *topologySubscription) subscribe(ctx context.Context, exclude *sonos.ZonePlayer) {
+	for _, zp := range t.players {
+		if exclude != nil && zp.UUID() == exclude.UUID() {
+			continue
+		}
+		opts := &sonos.SubscriptionOptions{ZonePlayer: zp, Service: zp.ZoneGroupTopology, EventHandler: t.bridge.Handler(zonePlayer{zp})}
+		if err := t.m.Subscribe(ctx, opts); err != nil {
+			t.logger.Error("failed to subscribe to the topology", "room", zp.RoomName(), "error", err)
+			continue
+		}
+		t.opts = opts
+		return
+	}
+}
@@ -34,7 +34,7 @@ package didl
 
 import (
 	"encoding/xml"
-	"log"
+	"log/slog"
 	"strings"
 )
 
@@ -42,14 +42,36 @@ type didlValidated struct {
 	Extra []xml.Name `xml:",any"`
 }
 
+// Validate reports the unexpected elements to slog.Default().
 func (d *didlValidated) Validate() {
+	d.ValidateWithLogger(slog.Default())
+}
+
+// ValidateWithLogger reports the unexpected elements to the logger.
+func (d *didlValidated) ValidateWithLogger(logger *slog.Logger) {
 	if 0 < len(d.Extra) {
 		for _, extra := range d.Extra {
-			log.Printf("Missing <Container><%s/>", extra.Local)
+			logger.Debug("unexpected DIDL element", "element", extra.Local)
 		}
 	}
 }
 
+// value is the underlying type of the single valued elements, e.g. Title.
+type value interface {
+	~struct {
+		XMLName xml.Name `json:"-"`
+		Value   string   `xml:",chardata"`
+	}
+}
+
+// First returns the value of the first element or an empty string.
+func First[T value](values []T) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return Title(values[0]).Value
+}
+
 type Album struct {
 	XMLName xml.Name `json:"-"`
 	Value   string   `xml:",chardata"`
@@ -75,10 +97,16 @@ type AlbumArtist struct {
 	Value   string   `xml:",chardata"`
 }
 
+// StreamContent is the content currently streamed by a radio station.
+type StreamContent struct {
+	XMLName xml.Name `json:"-"`
+	Value   string   `xml:",chardata"`
+}
+
 type Res struct {
 	XMLName      xml.Name `json:"-"`
 	ProtocolInfo string   `xml:"protocolInfo,attr"`
-	Duration     string   `xml:"duration,attr"`
+	Duration     string   `xml:"duration,attr,omitempty"`
 	Value        string   `xml:",chardata"`
 }
 type Title struct {
@@ -122,6 +150,8 @@ type Item struct {
 	Album       []Album       `xml:"urn:schemas-upnp-org:metadata-1-0/upnp/ album"`
 	AlbumArtist []AlbumArtist `xml:"urn:schemas-rinconnetworks-com:metadata-1-0/ albumArtist"`
 	Desc        []Desc        `xml:"desc"`
+	// Set by radio stations
+	StreamContent []StreamContent `xml:"urn:schemas-rinconnetworks-com:metadata-1-0/ streamContent"`
 	didlValidated
 }
 
@@ -132,6 +162,9 @@ type Lite struct {
 	didlValidated
 }
 
+// Namespace is the namespace of DIDL-Lite documents.
+const Namespace = "urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/"
+
 const emptyDocument = "<DIDL-Lite xmlns=\"urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/\"></DIDL-Lite>"
 
 func EmptyDocument() string {
@@ -145,3 +178,17 @@ func EmptyDocuments(num int) string {
 	}
 	return docs
 }
+
+// Document returns the DIDL-Lite document of the items, e.g. the metadata of a URI.
+func Document(items ...Item) (string, error) {
+	// The namespace is set as an attribute, as the items would be marshalled without it otherwise
+	b, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"DIDL-Lite"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Item    []Item   `xml:"item"`
+	}{Xmlns: Namespace, Item: items})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
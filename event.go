@@ -2,11 +2,32 @@ package sonos
 
 import (
 	"fmt"
+	"path"
+	"reflect"
 	"strings"
 
+	"github.com/caglar10ur/sonos/didl"
 	"github.com/kr/pretty"
 )
 
+// RawEvent is an event without a typed representation, or one that failed to parse.
+type RawEvent struct {
+	// Name of the service package, e.g. AVTransport
+	Service string
+	// Name of the state variable
+	Name  string
+	Value string
+}
+
+func newRawEvent(evt interface{}) RawEvent {
+	t := reflect.TypeOf(evt)
+	return RawEvent{
+		Service: path.Base(t.PkgPath()),
+		Name:    t.Name(),
+		Value:   fmt.Sprint(evt),
+	}
+}
+
 // http://upnp.org/specs/av/UPnP-av-AVTransport-v1-Service.pdf
 type AVTransportLastChange struct {
 	InstanceID AVTransportInstanceID `xml:"InstanceID"`
@@ -49,6 +70,9 @@ type AVTransportInstanceID struct {
 	RecordMediumWriteStatus      RecordMediumWriteStatus      `xml:"RecordMediumWriteStatus"`
 	CurrentRecordQualityMode     CurrentRecordQualityMode     `xml:"CurrentRecordQualityMode"`
 	PossibleRecordQualityModes   PossibleRecordQualityModes   `xml:"PossibleRecordQualityModes"`
+
+	// names of the elements the event carried
+	present map[string]bool
 }
 
 type TransportState struct {
@@ -170,44 +194,30 @@ func (e *AVTransportLastChange) String() string {
 	fmt.Fprintf(&b, "CurrentTrackDuration: %s\n", e.InstanceID.CurrentTrackDuration.Value)
 	fmt.Fprintf(&b, "CurrentTrackURI: %s\n", e.InstanceID.CurrentTrackURI.Value)
 
-	metadata, err := ParseDIDL(e.InstanceID.CurrentTrackMetaData.Value)
-	if err == nil && len(metadata.Item) > 0 {
-		m := metadata.Item[0]
-
-		if len(m.Title) > 0 {
-			fmt.Fprintf(&b, "CurrentTrackMetaData>Title: %s\n", m.Title[0].Value)
-		}
-		if len(m.Album) > 0 {
-			fmt.Fprintf(&b, "CurrentTrackMetaData>Album: %s\n", m.Album[0].Value)
-		}
-		if len(m.Creator) > 0 {
-			fmt.Fprintf(&b, "CurrentTrackMetaData>Creator: %s\n", m.Creator[0].Value)
-		}
-		if len(m.AlbumArtURI) > 0 {
-			fmt.Fprintf(&b, "CurrentTrackMetaData>AlbumArtURI: %s\n", m.AlbumArtURI[0].Value)
-		}
-	}
+	writeMetaData(&b, "CurrentTrackMetaData", e.InstanceID.CurrentTrackMetaData.Value)
 
 	fmt.Fprintf(&b, "NextTrackURI: %s\n", e.InstanceID.NextTrackURI.Value)
-	metadata, err = ParseDIDL(e.InstanceID.NextTrackMetaData.Value)
-	if err == nil && len(metadata.Item) > 0 {
-		m := metadata.Item[0]
+	writeMetaData(&b, "NextTrackMetaData", e.InstanceID.NextTrackMetaData.Value)
 
-		if len(m.Title) > 0 {
-			fmt.Fprintf(&b, "NextTrackMetaData>Title: %s\n", m.Title[0].Value)
-		}
-		if len(m.Album) > 0 {
-			fmt.Fprintf(&b, "NextTrackMetaData>Album: %s\n", m.Album[0].Value)
-		}
-		if len(m.Creator) > 0 {
-			fmt.Fprintf(&b, "NextTrackMetaData>Creator: %s\n", m.Creator[0].Value)
-		}
-		if len(m.AlbumArtURI) > 0 {
-			fmt.Fprintf(&b, "NextTrackMetaData>AlbumArtURI: %s\n", m.AlbumArtURI[0].Value)
+	return b.String()
+}
+
+// writeMetaData writes the fields the DIDL-Lite metadata has.
+func writeMetaData(b *strings.Builder, name, raw string) {
+	m := parseItem(raw)
+	if m == nil {
+		return
+	}
+	for _, field := range []struct{ name, value string }{
+		{"Title", didl.First(m.Title)},
+		{"Album", didl.First(m.Album)},
+		{"Creator", didl.First(m.Creator)},
+		{"AlbumArtURI", didl.First(m.AlbumArtURI)},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(b, "%s>%s: %s\n", name, field.name, field.value)
 		}
 	}
-
-	return b.String()
 }
 
 // http://upnp.org/specs/av/UPnP-av-RenderingControl-v1-Service.pdf
@@ -217,34 +227,60 @@ type RenderingControlLastChange struct {
 
 type RenderingControlInstanceID struct {
 	Volume                    []Volume                  `xml:"Volume"`
+	VolumeDB                  []VolumeDB                `xml:"VolumeDB"`
 	Mute                      []Mute                    `xml:"Mute"`
-	Bass                      Bass                      `xml:"Bass"`
-	Treble                    Treble                    `xml:"Treble"`
+	Bass                      []Bass                    `xml:"Bass"`
+	Treble                    []Treble                  `xml:"Treble"`
 	Loudness                  Loudness                  `xml:"Loudness"`
 	OutputFixed               OutputFixed               `xml:"OutputFixed"`
+	HeadphoneConnected        HeadphoneConnected        `xml:"HeadphoneConnected"`
 	SpeakerSize               SpeakerSize               `xml:"SpeakerSize"`
-	SubGain                   SubGain                   `xml:"SubGain"`
+	SubGain                   []SubGain                 `xml:"SubGain"`
 	SubCrossover              SubCrossover              `xml:"SubCrossover"`
 	SubPolarity               SubPolarity               `xml:"SubPolarity"`
 	SubEnabled                SubEnabled                `xml:"SubEnabled"`
 	SonarEnabled              SonarEnabled              `xml:"SonarEnabled"`
 	SonarCalibrationAvailable SonarCalibrationAvailable `xml:"SonarCalibrationAvailable"`
 	PresetNameList            PresetNameList            `xml:"PresetNameList"`
+	DialogLevel               DialogLevel               `xml:"DialogLevel"`
+	SpeechEnhanceEnabled      SpeechEnhanceEnabled      `xml:"SpeechEnhanceEnabled"`
+	NightMode                 NightMode                 `xml:"NightMode"`
+	SurroundEnabled           SurroundEnabled           `xml:"SurroundEnabled"`
+	SurroundMode              SurroundMode              `xml:"SurroundMode"`
+	SurroundLevel             SurroundLevel             `xml:"SurroundLevel"`
+	MusicSurroundLevel        MusicSurroundLevel        `xml:"MusicSurroundLevel"`
+	AudioDelay                AudioDelay                `xml:"AudioDelay"`
+	AudioDelayLeftRear        AudioDelayLeftRear        `xml:"AudioDelayLeftRear"`
+	AudioDelayRightRear       AudioDelayRightRear       `xml:"AudioDelayRightRear"`
+	HeightChannelLevel        HeightChannelLevel        `xml:"HeightChannelLevel"`
+
+	// Values of the elements without a field keyed by element name, or by element name and
+	// channel as "Name/Channel" for per channel values
+	Unknown map[string]string `xml:"-"`
+
+	// names of the elements the event carried
+	present map[string]bool
 }
 
 type Volume struct {
 	Channel string `xml:"channel,attr"`
 	Value   string `xml:"val,attr"`
 }
+type VolumeDB struct {
+	Channel string `xml:"channel,attr"`
+	Value   string `xml:"val,attr"`
+}
 type Mute struct {
 	Channel string `xml:"channel,attr"`
 	Value   string `xml:"val,attr"`
 }
 type Bass struct {
-	Value string `xml:"val,attr"`
+	Channel string `xml:"channel,attr"`
+	Value   string `xml:"val,attr"`
 }
 type Treble struct {
-	Value string `xml:"val,attr"`
+	Channel string `xml:"channel,attr"`
+	Value   string `xml:"val,attr"`
 }
 type Loudness struct {
 	Channel string `xml:"channel,attr"`
@@ -253,11 +289,15 @@ type Loudness struct {
 type OutputFixed struct {
 	Value string `xml:"val,attr"`
 }
+type HeadphoneConnected struct {
+	Value string `xml:"val,attr"`
+}
 type SpeakerSize struct {
 	Value string `xml:"val,attr"`
 }
 type SubGain struct {
-	Value string `xml:"val,attr"`
+	Channel string `xml:"channel,attr"`
+	Value   string `xml:"val,attr"`
 }
 type SubCrossover struct {
 	Value string `xml:"val,attr"`
@@ -277,6 +317,39 @@ type SonarCalibrationAvailable struct {
 type PresetNameList struct {
 	Value string `xml:"val,attr"`
 }
+type DialogLevel struct {
+	Value string `xml:"val,attr"`
+}
+type SpeechEnhanceEnabled struct {
+	Value string `xml:"val,attr"`
+}
+type NightMode struct {
+	Value string `xml:"val,attr"`
+}
+type SurroundEnabled struct {
+	Value string `xml:"val,attr"`
+}
+type SurroundMode struct {
+	Value string `xml:"val,attr"`
+}
+type SurroundLevel struct {
+	Value string `xml:"val,attr"`
+}
+type MusicSurroundLevel struct {
+	Value string `xml:"val,attr"`
+}
+type AudioDelay struct {
+	Value string `xml:"val,attr"`
+}
+type AudioDelayLeftRear struct {
+	Value string `xml:"val,attr"`
+}
+type AudioDelayRightRear struct {
+	Value string `xml:"val,attr"`
+}
+type HeightChannelLevel struct {
+	Value string `xml:"val,attr"`
+}
 
 func (e *RenderingControlLastChange) String() string {
 	return pretty.Sprintf("%# v", e)
@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/caglar10ur/sonos"
-	avtransport "github.com/caglar10ur/sonos/services/AVTransport"
 )
 
 func main() {
@@ -23,35 +22,29 @@ func main() {
 	f := func(s *sonos.Sonos, player *sonos.ZonePlayer) {
 		fmt.Printf("%s\t%s\t%s\n", player.RoomName(), player.ModelName(), player.SerialNumber())
 
-		az, err := player.AVTransport.GetPositionInfo(&avtransport.GetPositionInfoArgs{})
+		track, err := player.NowPlaying(ctx)
 		if err != nil {
 			fmt.Printf("%s", err)
 			return
-
 		}
-
-		if len(az.TrackMetaData) == 0 {
-			return
-		}
-
-		metadata, err := sonos.ParseDIDL(az.TrackMetaData)
-		if err != nil {
-			fmt.Printf("%s", err)
+		if track.Source == sonos.SourceNone {
 			return
 		}
 
 		fmt.Printf("### Now playing ###\n")
-		for _, m := range metadata.Item {
-			if len(m.Title) > 0 {
-				fmt.Printf("Title: %s\n", m.Title[0].Value)
-			}
-			if len(m.Album) > 0 {
-				fmt.Printf("Album: %s\n", m.Album[0].Value)
-			}
-			if len(m.Creator) > 0 {
-				fmt.Printf("Creator: %s\n\n", m.Creator[0].Value)
-			}
+		if track.Station != "" {
+			fmt.Printf("Station: %s\n", track.Station)
+		}
+		if track.Title != "" {
+			fmt.Printf("Title: %s\n", track.Title)
+		}
+		if track.Album != "" {
+			fmt.Printf("Album: %s\n", track.Album)
+		}
+		if track.Artist != "" {
+			fmt.Printf("Creator: %s\n", track.Artist)
 		}
+		fmt.Printf("Position: %s/%s\n\n", track.Position, track.Duration)
 	}
 
 	err = s.Search(ctx, f)
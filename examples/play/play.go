@@ -35,13 +35,8 @@ func main() {
 		return
 	}
 
-	if err = zp.SetAVTransportURI(os.Args[2]); err != nil {
-		fmt.Printf("SetAVTransportURI Error: %v\n", err)
-		return
-	}
-
-	if err = zp.Play(); err != nil {
-		fmt.Printf("Play Error: %v\n", err)
+	if err = zp.PlayURI(ctx, os.Args[2], nil); err != nil {
+		fmt.Printf("PlayURI Error: %v\n", err)
 		return
 	}
 }
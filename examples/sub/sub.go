@@ -81,44 +81,24 @@ func main() {
 		zp.ZoneGroupTopology,
 	}
 
-	o2s := make(map[string]*sonos.SubscriptionOptions)
+	m := sonos.NewSubscriptionManager(son)
+	defer m.Close(context.Background())
+
 	for i := range services {
 		opts := &sonos.SubscriptionOptions{
 			ZonePlayer:   zp,
 			Service:      services[i],
 			EventHandler: f,
 		}
-		if err := opts.Validate(); err != nil {
-			log.Fatalf("%s", err)
-		}
-
-		sid, err := son.Subscribe(ctx, opts)
-		if err != nil {
-			log.Fatalf("%s", err)
-		}
-		opts.SetSid(sid)
-
-		o2s[sid] = opts
-	}
-
-	time.Sleep(10 * time.Second)
-	for _, v := range o2s {
-		fmt.Printf("Renewing %T\n", v.Service)
-		err = son.Renew(ctx, v)
-		if err != nil {
-			log.Fatalf("%s", err)
-		}
-	}
-
-	time.Sleep(10 * time.Second)
-	for _, v := range o2s {
-		fmt.Printf("Unsubscribing %T\n", v.Service)
-		err = son.Unsubscribe(ctx, v)
-		if err != nil {
+		if err := m.Subscribe(ctx, opts); err != nil {
 			log.Fatalf("%s", err)
 		}
 	}
 
 	fmt.Printf("Waiting...\n")
 	<-ctx.Done()
+
+	for _, h := range m.Health() {
+		fmt.Printf("%T\t%s\t%s\t%d failures\n", h.Options.Service, h.Sid, h.State, h.Failures)
+	}
 }
@@ -0,0 +1,219 @@
+package sonos
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeDevice is an HTTP server standing in for a ZonePlayer. It serves the device description,
+// answers SOAP actions through handlers and implements the GENA subscription lifecycle.
+type fakeDevice struct {
+	server   *httptest.Server
+	handlers map[string]func(*http.Request) (*http.Response, error)
+	// Served device description, mockDeviceDescription by default
+	description string
+	// Seconds granted to subscriptions
+	timeout int
+	// Event sent to new subscriptions before the SUBSCRIBE response, like a device racing its reply
+	initialEvent string
+	// Initial events keyed by the event path of the service, taking precedence over initialEvent
+	initialEvents map[string]string
+
+	mu sync.Mutex
+	// Callback URLs keyed by SID
+	subscriptions map[string]string
+	seqs          map[string]int
+	renewals      int
+	unsubscribed  []string
+}
+
+// fakeSids numbers the subscriptions of every fake device, keeping SIDs unique when a test
+// subscribes to several devices through one listener.
+var fakeSids atomic.Uint64
+
+func newFakeDevice(t *testing.T, handlers map[string]func(*http.Request) (*http.Response, error)) *fakeDevice {
+	d := &fakeDevice{
+		handlers:      handlers,
+		description:   mockDeviceDescription,
+		timeout:       3600,
+		subscriptions: make(map[string]string),
+		seqs:          make(map[string]int),
+	}
+	d.server = httptest.NewServer(d)
+	t.Cleanup(d.server.Close)
+	return d
+}
+
+// ZonePlayer returns a ZonePlayer talking to the fake device.
+func (d *fakeDevice) ZonePlayer(t *testing.T) *ZonePlayer {
+	loc, _ := url.Parse(d.server.URL + "/xml/device_description.xml")
+	zp, err := NewZonePlayer(WithLocation(loc))
+	if err != nil {
+		t.Fatalf("Failed to create ZonePlayer for the fake device: %v", err)
+	}
+	return zp
+}
+
+// URL returns the base URL of the device.
+func (d *fakeDevice) URL() string {
+	return d.server.URL
+}
+
+// Reboot forgets every subscription like a restarted device does.
+func (d *fakeDevice) Reboot() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions = make(map[string]string)
+}
+
+// Sids returns the active subscription ids.
+func (d *fakeDevice) Sids() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var sids []string
+	for sid := range d.subscriptions {
+		sids = append(sids, sid)
+	}
+	return sids
+}
+
+// Callback returns the callback URL of sid.
+func (d *fakeDevice) Callback(sid string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.subscriptions[sid]
+}
+
+func (d *fakeDevice) Renewals() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.renewals
+}
+
+func (d *fakeDevice) Unsubscribed() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.unsubscribed...)
+}
+
+func (d *fakeDevice) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	switch req.Method {
+	case "SUBSCRIBE":
+		d.subscribe(w, req)
+	case "UNSUBSCRIBE":
+		d.mu.Lock()
+		sid := req.Header.Get("SID")
+		_, ok := d.subscriptions[sid]
+		delete(d.subscriptions, sid)
+		if ok {
+			d.unsubscribed = append(d.unsubscribed, sid)
+		}
+		d.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}
+	default:
+		if strings.Contains(req.URL.Path, "device_description.xml") {
+			io.WriteString(w, d.description)
+			return
+		}
+		handler, ok := d.handlers[strings.Trim(req.Header.Get("SOAPAction"), "\"")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		res, err := handler(req)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer res.Body.Close()
+		w.WriteHeader(res.StatusCode)
+		io.Copy(w, res.Body)
+	}
+}
+
+func (d *fakeDevice) subscribe(w http.ResponseWriter, req *http.Request) {
+	d.mu.Lock()
+	sid := req.Header.Get("SID")
+	renewal := sid != ""
+	if renewal {
+		if _, ok := d.subscriptions[sid]; !ok {
+			d.mu.Unlock()
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		d.renewals++
+	} else {
+		sid = fmt.Sprintf("uuid:RINCON_000E58CDCA4001400_sub%010d", fakeSids.Add(1))
+		d.subscriptions[sid] = strings.Trim(req.Header.Get("CALLBACK"), "<>")
+	}
+	d.mu.Unlock()
+
+	initialEvent, ok := d.initialEvents[req.URL.Path]
+	if !ok {
+		initialEvent = d.initialEvent
+	}
+	if !renewal && initialEvent != "" {
+		if status, err := d.Notify(sid, initialEvent); err != nil || status != http.StatusOK {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("SID", sid)
+	w.Header().Set("TIMEOUT", fmt.Sprintf("Second-%d", d.timeout))
+	w.WriteHeader(http.StatusOK)
+}
+
+// Notify sends an event to the subscriber of sid and returns the status it answered with.
+func (d *fakeDevice) Notify(sid, body string) (int, error) {
+	d.mu.Lock()
+	callback, ok := d.subscriptions[sid]
+	seq := d.seqs[sid]
+	d.seqs[sid]++
+	d.mu.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("unknown subscription %s", sid)
+	}
+
+	req, err := http.NewRequest("NOTIFY", callback, strings.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("CONTENT-TYPE", `text/xml; charset="utf-8"`)
+	req.Header.Set("NT", "upnp:event")
+	req.Header.Set("NTS", "upnp:propchange")
+	req.Header.Set("SID", sid)
+	req.Header.Set("SEQ", strconv.Itoa(seq))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	return res.StatusCode, nil
+}
+
+// Skip drops the next event of sid as if it got lost on the way.
+func (d *fakeDevice) Skip(sid string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seqs[sid]++
+}
+
+// lastChangeEvent wraps a LastChange document in an event notification body.
+func lastChangeEvent(lastChange string) string {
+	return `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><LastChange>` + html.EscapeString(lastChange) + `</LastChange></e:property></e:propertyset>`
+}
@@ -0,0 +1,260 @@
+package sonos
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+var (
+	// How long HandoffTo waits for the topology to report the new coordinator
+	handoffTimeout = 10 * time.Second
+	// How often the topology is polled while waiting for it to reflect a change
+	topologyPollInterval = 250 * time.Millisecond
+)
+
+// Group is a zone group as reported by the topology at the time it was looked up.
+type Group struct {
+	ID          string
+	Coordinator *ZonePlayer
+	Members     []*ZonePlayer
+
+	sonos *Sonos
+}
+
+// Group returns the group the ZonePlayer currently belongs to.
+func (z *ZonePlayer) Group() (*Group, error) {
+	zoneGroupState, err := z.GetZoneGroupState()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range zoneGroupState.ZoneGroups {
+		var found bool
+		for _, member := range group.ZoneGroupMember {
+			if member.UUID == z.UUID() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		g := &Group{ID: group.ID}
+		for _, member := range group.VisibleMembers() {
+			zp, err := z.zonePlayerAt(member.UUID, member.Location)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", member.ZoneName, err)
+			}
+			if member.UUID == group.Coordinator {
+				g.Coordinator = zp
+			}
+			g.Members = append(g.Members, zp)
+		}
+		if g.Coordinator == nil {
+			return nil, fmt.Errorf("coordinator %s of group %s not found", group.Coordinator, group.ID)
+		}
+		return g, nil
+	}
+
+	return nil, fmt.Errorf("ZonePlayer %s is not part of any group", z.UUID())
+}
+
+// Group returns the group the ZonePlayer currently belongs to. Subscriptions made through
+// s follow the coordinator when the returned group is handed off to another member.
+func (s *Sonos) Group(zp *ZonePlayer) (*Group, error) {
+	g, err := zp.Group()
+	if err != nil {
+		return nil, err
+	}
+	g.sonos = s
+	return g, nil
+}
+
+// Member returns the member with the given UUID or nil.
+func (g *Group) Member(uuid string) *ZonePlayer {
+	for _, zp := range g.Members {
+		if zp.UUID() == uuid {
+			return zp
+		}
+	}
+	return nil
+}
+
+// HandoffTo moves the coordination of the group to another member without interrupting playback.
+// It returns once the topology reports zp as the new coordinator.
+//
+// When keepPlaying is set and the group was playing before the handoff, playback is resumed
+// on the new coordinator should it have stopped during the handoff.
+func (g *Group) HandoffTo(ctx context.Context, zp *ZonePlayer, keepPlaying bool) error {
+	old := g.Coordinator
+	if zp.UUID() == old.UUID() {
+		return nil
+	}
+	if g.Member(zp.UUID()) == nil {
+		return fmt.Errorf("%s is not a member of group %s", zp.RoomName(), g.ID)
+	}
+
+	var wasPlaying bool
+	if keepPlaying {
+		info, err := old.AVTransport.GetTransportInfo(&avt.GetTransportInfoArgs{InstanceID: 0})
+		if err != nil {
+			return err
+		}
+		wasPlaying = info.CurrentTransportState == avt.TransportState_PLAYING
+	}
+
+	_, err := old.AVTransport.DelegateGroupCoordinationTo(&avt.DelegateGroupCoordinationToArgs{
+		InstanceID:     0,
+		NewCoordinator: zp.UUID(),
+		RejoinGroup:    true,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, handoffTimeout)
+	defer cancel()
+
+	id, err := waitForCoordinator(ctx, zp)
+	if err != nil {
+		return err
+	}
+	g.ID = id
+	g.Coordinator = zp
+
+	if g.sonos != nil {
+		if err := g.sonos.moveSubscriptions(ctx, old, zp); err != nil {
+			return err
+		}
+	}
+
+	if wasPlaying {
+		info, err := zp.AVTransport.GetTransportInfo(&avt.GetTransportInfoArgs{InstanceID: 0})
+		if err != nil {
+			return err
+		}
+		if info.CurrentTransportState != avt.TransportState_PLAYING && info.CurrentTransportState != avt.TransportState_TRANSITIONING {
+			return zp.Play()
+		}
+	}
+
+	return nil
+}
+
+// waitForCoordinator waits for the topology to report zp as the coordinator of its group and
+// returns the ID of that group. It follows the ZoneGroupTopology events of zp and polls the
+// topology while there is no subscription to them.
+func waitForCoordinator(ctx context.Context, zp *ZonePlayer) (string, error) {
+	var mu sync.Mutex
+	var id string
+	p := coordinating()
+	match := p.Match
+	p.Match = func(v interface{}) bool {
+		if !match(v) {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		id = v.(string)
+		return true
+	}
+
+	if err := zp.WaitFor(ctx, p, WithPollInterval(topologyPollInterval)); err != nil {
+		return "", err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return id, nil
+}
+
+// coordinating holds once the player coordinates its group. The observed state is the ID of
+// the group, empty while another player coordinates it.
+func coordinating() Predicate {
+	groupID := func(zp *ZonePlayer, groups []ZoneGroup) string {
+		for _, group := range groups {
+			if group.Coordinator == zp.UUID() {
+				return group.ID
+			}
+		}
+		return ""
+	}
+	return Predicate{
+		Name:    "coordinating its group",
+		Service: func(zp *ZonePlayer) SonosService { return zp.ZoneGroupTopology },
+		Query: func(zp *ZonePlayer) (interface{}, error) {
+			state, err := zp.GetZoneGroupState()
+			if err != nil {
+				return nil, err
+			}
+			return groupID(zp, state.ZoneGroups), nil
+		},
+		Observe: func(zp *ZonePlayer, evt interface{}) (interface{}, bool) {
+			if e, ok := evt.(ZoneGroupTopologyZoneGroupState); ok {
+				return groupID(zp, e.ZoneGroups.ZoneGroup), true
+			}
+			return nil, false
+		},
+		Match: func(v interface{}) bool {
+			return v != ""
+		},
+	}
+}
+
+// moveSubscriptions moves the coordinator scoped subscriptions from one ZonePlayer to another.
+// The subscriptions maintained by a SubscriptionManager are moved by it, the others are
+// unsubscribed before their options change.
+func (s *Sonos) moveSubscriptions(ctx context.Context, from, to *ZonePlayer) error {
+	s.zonePlayers.LoadOrStore(to.SerialNumber(), to)
+
+	var moved []*SubscriptionOptions
+	s.subscriptions.Range(func(key, value any) bool {
+		opts := value.(*SubscriptionOptions)
+		if opts.ZonePlayer.UUID() == from.UUID() && to.coordinatorService(opts.Service) != nil {
+			moved = append(moved, opts)
+		}
+		return true
+	})
+
+	for _, opts := range moved {
+		var managed bool
+		var err error
+		s.managers.Range(func(key, _ any) bool {
+			managed, err = key.(*SubscriptionManager).move(ctx, opts, to)
+			return !managed
+		})
+		if managed {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// The old coordinator may have already dropped the subscription
+		_ = s.Unsubscribe(ctx, opts)
+
+		opts.ZonePlayer = to
+		opts.Service = to.coordinatorService(opts.Service)
+		if _, err := s.Subscribe(ctx, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// coordinatorService returns the ZonePlayer's counterpart of the given service if it only
+// produces meaningful events on the group coordinator.
+func (z *ZonePlayer) coordinatorService(service SonosService) SonosService {
+	switch service.EventEndpoint().Path {
+	case z.AVTransport.EventEndpoint().Path:
+		return z.AVTransport
+	case z.GroupRenderingControl.EventEndpoint().Path:
+		return z.GroupRenderingControl
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package sonos
+
+import (
+	"bytes"
+	"context"
+	"html"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroupHandoffTo(t *testing.T) {
+	before := `&lt;ZoneGroupState&gt;&lt;ZoneGroups&gt;&lt;ZoneGroup Coordinator=&quot;RINCON_000E58CDCA4001400&quot; ID=&quot;RINCON_000E58CDCA4001400:1&quot;&gt;&lt;ZoneGroupMember UUID=&quot;RINCON_000E58CDCA4001400&quot; Location=&quot;http://192.168.1.100:1400/xml/device_description.xml&quot; ZoneName=&quot;Kitchen&quot; /&gt;&lt;ZoneGroupMember UUID=&quot;RINCON_BBB&quot; Location=&quot;http://192.168.1.101:1400/xml/device_description.xml&quot; ZoneName=&quot;Office&quot; /&gt;&lt;/ZoneGroup&gt;&lt;/ZoneGroups&gt;&lt;/ZoneGroupState&gt;`
+	after := `&lt;ZoneGroupState&gt;&lt;ZoneGroups&gt;&lt;ZoneGroup Coordinator=&quot;RINCON_BBB&quot; ID=&quot;RINCON_BBB:2&quot;&gt;&lt;ZoneGroupMember UUID=&quot;RINCON_000E58CDCA4001400&quot; Location=&quot;http://192.168.1.100:1400/xml/device_description.xml&quot; ZoneName=&quot;Kitchen&quot; /&gt;&lt;ZoneGroupMember UUID=&quot;RINCON_BBB&quot; Location=&quot;http://192.168.1.101:1400/xml/device_description.xml&quot; ZoneName=&quot;Office&quot; /&gt;&lt;/ZoneGroup&gt;&lt;/ZoneGroups&gt;&lt;/ZoneGroupState&gt;`
+
+	var delegated, played bool
+	handlers := map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:ZoneGroupTopology:1#GetZoneGroupState": func(req *http.Request) (*http.Response, error) {
+			state := before
+			if delegated {
+				state = after
+			}
+			return mockResponseHandler("ZoneGroupTopology", "GetZoneGroupState", "<ZoneGroupState>"+state+"</ZoneGroupState>")(req)
+		},
+		"urn:schemas-upnp-org:service:AVTransport:1#GetTransportInfo": func(req *http.Request) (*http.Response, error) {
+			state := "PLAYING"
+			if delegated && req.URL.Host == "192.168.1.101:1400" {
+				state = "STOPPED"
+			}
+			return mockResponseHandler("AVTransport", "GetTransportInfo", "<CurrentTransportState>"+state+"</CurrentTransportState>")(req)
+		},
+		"urn:schemas-upnp-org:service:AVTransport:1#DelegateGroupCoordinationTo": func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			if !bytes.Contains(body, []byte("<NewCoordinator>RINCON_BBB</NewCoordinator>")) {
+				t.Errorf("Expected NewCoordinator RINCON_BBB in request, got: %s", string(body))
+			}
+			if req.URL.Host != "192.168.1.100:1400" {
+				t.Errorf("Expected the old coordinator to delegate, got %s", req.URL.Host)
+			}
+			delegated = true
+			return mockSuccessHandler("AVTransport", "DelegateGroupCoordinationTo")(req)
+		},
+		"urn:schemas-upnp-org:service:AVTransport:1#Play": func(req *http.Request) (*http.Response, error) {
+			if req.URL.Host != "192.168.1.101:1400" {
+				t.Errorf("Expected the new coordinator to resume playback, got %s", req.URL.Host)
+			}
+			played = true
+			return mockSuccessHandler("AVTransport", "Play")(req)
+		},
+	}
+
+	zp := NewMockZonePlayer(t, handlers)
+	zp.Client().Transport.(*MockRoundTripper).Descriptions = map[string]string{
+		"192.168.1.101:1400": mockDeviceDescriptionFor("Office", "RINCON_BBB"),
+	}
+
+	g, err := zp.Group()
+	if err != nil {
+		t.Fatalf("Group failed: %v", err)
+	}
+	if len(g.Members) != 2 || g.Coordinator.UUID() != "RINCON_000E58CDCA4001400" {
+		t.Fatalf("Unexpected group: %+v", g)
+	}
+
+	office := g.Member("RINCON_BBB")
+	if office == nil {
+		t.Fatal("Expected Office to be a member")
+	}
+
+	if err := g.HandoffTo(context.Background(), office, true); err != nil {
+		t.Fatalf("HandoffTo failed: %v", err)
+	}
+
+	if g.Coordinator != office || g.ID != "RINCON_BBB:2" {
+		t.Errorf("Expected Office to coordinate RINCON_BBB:2, got %s %s", g.Coordinator.RoomName(), g.ID)
+	}
+	if !played {
+		t.Error("Expected playback to be resumed on the new coordinator")
+	}
+}
+
+func TestGroupHandoffToMovesManagedSubscriptions(t *testing.T) {
+	// Only the topology events report the handoff
+	interval := topologyPollInterval
+	topologyPollInterval = time.Hour
+	defer func() { topologyPollInterval = interval }()
+
+	var kitchen, office *fakeDevice
+	topology := func(coordinator, id string) string {
+		return `<ZoneGroupState><ZoneGroups><ZoneGroup Coordinator="` + coordinator + `" ID="` + id + `"><ZoneGroupMember UUID="RINCON_000E58CDCA4001400" Location="` + kitchen.URL() + `/xml/device_description.xml" ZoneName="Kitchen"/><ZoneGroupMember UUID="RINCON_BBB" Location="` + office.URL() + `/xml/device_description.xml" ZoneName="Office"/></ZoneGroup></ZoneGroups></ZoneGroupState>`
+	}
+	getZoneGroupState := func(req *http.Request) (*http.Response, error) {
+		return mockResponseHandler("ZoneGroupTopology", "GetZoneGroupState", "<ZoneGroupState>"+escapeXML(topology("RINCON_000E58CDCA4001400", "RINCON_000E58CDCA4001400:1"))+"</ZoneGroupState>")(req)
+	}
+	delegated := make(chan struct{})
+	kitchen = newFakeDevice(t, map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:ZoneGroupTopology:1#GetZoneGroupState": getZoneGroupState,
+		"urn:schemas-upnp-org:service:AVTransport:1#DelegateGroupCoordinationTo": func(req *http.Request) (*http.Response, error) {
+			close(delegated)
+			return mockSuccessHandler("AVTransport", "DelegateGroupCoordinationTo")(req)
+		},
+	})
+	office = newFakeDevice(t, map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:ZoneGroupTopology:1#GetZoneGroupState": getZoneGroupState,
+	})
+	office.description = mockDeviceDescriptionFor("Office", "RINCON_BBB")
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+	m := NewSubscriptionManager(s)
+
+	g, err := s.Group(kitchen.ZonePlayer(t))
+	if err != nil {
+		t.Fatalf("Group failed: %v", err)
+	}
+	ozp := g.Member("RINCON_BBB")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var mu sync.Mutex
+	var events []interface{}
+	opts := &SubscriptionOptions{ZonePlayer: g.Coordinator, Service: g.Coordinator.AVTransport, EventHandler: func(evt interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, evt)
+	}}
+	if err := m.Subscribe(ctx, opts); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := m.Subscribe(ctx, &SubscriptionOptions{ZonePlayer: ozp, Service: ozp.ZoneGroupTopology}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	// Keep reporting the new topology until the handoff saw it
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		<-delegated
+		sid := sidFor(t, office, "/ZoneGroupTopology/Event")
+		body := `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><ZoneGroupState>` + html.EscapeString(topology("RINCON_BBB", "RINCON_BBB:2")) + `</ZoneGroupState></e:property></e:propertyset>`
+		for {
+			_, _ = office.Notify(sid, body)
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}()
+
+	if err := g.HandoffTo(ctx, ozp, false); err != nil {
+		t.Fatalf("HandoffTo failed: %v", err)
+	}
+	if g.ID != "RINCON_BBB:2" {
+		t.Errorf("Expected RINCON_BBB:2, got %s", g.ID)
+	}
+	if len(kitchen.Sids()) != 0 || len(office.Sids()) != 2 {
+		t.Fatalf("Expected the AVTransport subscription to move to Office, got %v %v", kitchen.Sids(), office.Sids())
+	}
+
+	// The manager keeps maintaining the moved subscription
+	var found bool
+	for _, h := range m.Health() {
+		if h.Options == opts {
+			found = h.State == SubscriptionActive && h.Sid == sidFor(t, office, "/MediaRenderer/AVTransport/Event") && opts.ZonePlayer == ozp
+		}
+	}
+	if !found {
+		t.Errorf("Expected the manager to maintain the moved subscription, got %+v", m.Health())
+	}
+
+	status, err := office.Notify(sidFor(t, office, "/MediaRenderer/AVTransport/Event"), lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/></InstanceID></Event>`))
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Notify failed: %d %v", status, err)
+	}
+	mu.Lock()
+	if len(events) != 1 {
+		t.Errorf("Expected the event of the new coordinator, got %v", events)
+	}
+	mu.Unlock()
+
+	if err := m.Close(ctx); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
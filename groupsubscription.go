@@ -0,0 +1,214 @@
+package sonos
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// CoordinatorChangeEvent is delivered by a GroupSubscription when another player took over the
+// coordination of the group. It is followed by the full state of the new coordinator.
+type CoordinatorChangeEvent struct {
+	GroupID     string
+	Previous    *ZonePlayer
+	Coordinator *ZonePlayer
+}
+
+// GroupSubscription delivers the AVTransport and GroupRenderingControl events of a group from
+// whichever player currently coordinates it.
+type GroupSubscription struct {
+	manager *SubscriptionManager
+	fn      EventHandlerFunc
+
+	topology *SubscriptionOptions
+	// Subscriptions to the coordinator
+	subs []*SubscriptionOptions
+
+	// Latest topology, followed by the loop
+	latestMu sync.Mutex
+	latest   []ZoneGroup
+	wake     chan struct{}
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu          sync.Mutex
+	id          string
+	coordinator *ZonePlayer
+	// UUIDs of the members as of the latest topology
+	members []string
+}
+
+// SubscribeGroup subscribes to the AVTransport and GroupRenderingControl services of the group
+// coordinator and moves the subscriptions along when the coordinator of the group changes. The
+// group is identified by its ID or, once that changed, by its members.
+func (m *SubscriptionManager) SubscribeGroup(ctx context.Context, g *Group, fn EventHandlerFunc) (*GroupSubscription, error) {
+	gs := &GroupSubscription{
+		manager:     m,
+		fn:          fn,
+		wake:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		id:          g.ID,
+		coordinator: g.Coordinator,
+	}
+	for _, member := range g.Members {
+		gs.members = append(gs.members, member.UUID())
+	}
+
+	zp := g.Coordinator
+	for _, service := range []SonosService{zp.AVTransport, zp.GroupRenderingControl} {
+		opts := &SubscriptionOptions{ZonePlayer: zp, Service: service, EventHandler: fn}
+		if err := m.Subscribe(ctx, opts); err != nil {
+			return nil, errors.Join(err, gs.unsubscribe(ctx))
+		}
+		gs.subs = append(gs.subs, opts)
+	}
+
+	var loopCtx context.Context
+	loopCtx, gs.cancel = context.WithCancel(context.Background())
+	go gs.loop(loopCtx)
+
+	gs.topology = &SubscriptionOptions{ZonePlayer: zp, Service: zp.ZoneGroupTopology, EventHandler: gs.topologyEvent}
+	if err := m.Subscribe(ctx, gs.topology); err != nil {
+		gs.topology = nil
+		return nil, errors.Join(err, gs.Close(ctx))
+	}
+	return gs, nil
+}
+
+// Coordinator returns the player the events are currently delivered from.
+func (gs *GroupSubscription) Coordinator() *ZonePlayer {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	return gs.coordinator
+}
+
+// Close stops following the group and unsubscribes from its services.
+func (gs *GroupSubscription) Close(ctx context.Context) error {
+	gs.cancel()
+	<-gs.done
+	return gs.unsubscribe(ctx)
+}
+
+func (gs *GroupSubscription) unsubscribe(ctx context.Context) error {
+	var errs []error
+	for _, opts := range append(gs.subs, gs.topology) {
+		if opts == nil {
+			continue
+		}
+		if err := gs.manager.Unsubscribe(ctx, opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// topologyEvent hands the topology over to the loop as moving subscriptions needs requests to
+// the devices, which shouldn't hold up the event notification.
+func (gs *GroupSubscription) topologyEvent(evt interface{}) {
+	e, ok := evt.(ZoneGroupTopologyZoneGroupState)
+	if !ok {
+		return
+	}
+	gs.latestMu.Lock()
+	gs.latest = e.ZoneGroups.ZoneGroup
+	gs.latestMu.Unlock()
+
+	select {
+	case gs.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (gs *GroupSubscription) loop(ctx context.Context) {
+	defer close(gs.done)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gs.wake:
+		}
+
+		gs.latestMu.Lock()
+		groups := gs.latest
+		gs.latestMu.Unlock()
+		gs.follow(ctx, groups)
+	}
+}
+
+// follow moves the subscriptions to the coordinator of the group in the topology.
+func (gs *GroupSubscription) follow(ctx context.Context, groups []ZoneGroup) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	group := gs.find(groups)
+	if group == nil {
+		gs.manager.sonos.logger.Warn("group not found in topology", "group", gs.id)
+		return
+	}
+	gs.id = group.ID
+	gs.members = gs.members[:0]
+	var location string
+	for _, member := range group.ZoneGroupMember {
+		gs.members = append(gs.members, member.UUID)
+		if member.UUID == group.Coordinator {
+			location = member.Location
+		}
+	}
+	if group.Coordinator == gs.coordinator.UUID() {
+		return
+	}
+
+	zp, err := gs.coordinator.zonePlayerAt(group.Coordinator, location)
+	if err != nil {
+		gs.manager.sonos.logger.Warn("failed to reach coordinator", "group", gs.id, "coordinator", group.Coordinator, "error", err)
+		return
+	}
+	if gs.fn != nil {
+		gs.fn(CoordinatorChangeEvent{GroupID: gs.id, Previous: gs.coordinator, Coordinator: zp})
+	}
+	gs.coordinator = zp
+
+	ctx, cancel := context.WithTimeout(ctx, handoffTimeout)
+	defer cancel()
+	for _, opts := range gs.subs {
+		// Already moved if HandoffTo got to it first
+		if _, err := gs.manager.move(ctx, opts, zp); err != nil {
+			gs.manager.sonos.logger.Warn("failed to move subscription", "group", gs.id, "coordinator", zp.RoomName(), "error", err)
+		}
+	}
+}
+
+// find returns the group in the topology which is the followed one: the one with the same ID
+// or the one sharing the most members. The coordinator leaving the group ends up in a group of
+// its own, which isn't the followed one anymore.
+func (gs *GroupSubscription) find(groups []ZoneGroup) *ZoneGroup {
+	departed := func(group ZoneGroup) bool {
+		return len(gs.members) > 1 && len(group.ZoneGroupMember) == 1 && group.ZoneGroupMember[0].UUID == gs.coordinator.UUID()
+	}
+
+	for i, group := range groups {
+		if group.ID == gs.id && !departed(group) {
+			return &groups[i]
+		}
+	}
+
+	var best *ZoneGroup
+	var shared int
+	for i, group := range groups {
+		if departed(group) {
+			continue
+		}
+		var n int
+		for _, member := range group.ZoneGroupMember {
+			if slices.Contains(gs.members, member.UUID) {
+				n++
+			}
+		}
+		if n > shared {
+			best, shared = &groups[i], n
+		}
+	}
+	return best
+}
@@ -0,0 +1,109 @@
+package sonos
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubscribeGroup(t *testing.T) {
+	for name, groups := range map[string]string{
+		// Office takes over the group
+		"TakeOver": `<ZoneGroup Coordinator="RINCON_BBB" ID="RINCON_BBB:2"><ZoneGroupMember UUID="RINCON_000E58CDCA4001400" Location="%[1]s/xml/device_description.xml" ZoneName="Kitchen"/><ZoneGroupMember UUID="RINCON_BBB" Location="%[2]s/xml/device_description.xml" ZoneName="Office"/></ZoneGroup>`,
+		// Kitchen leaves the group, keeping its ID, and Office carries on
+		"CoordinatorLeaves": `<ZoneGroup Coordinator="RINCON_000E58CDCA4001400" ID="RINCON_000E58CDCA4001400:1"><ZoneGroupMember UUID="RINCON_000E58CDCA4001400" Location="%[1]s/xml/device_description.xml" ZoneName="Kitchen"/></ZoneGroup><ZoneGroup Coordinator="RINCON_BBB" ID="RINCON_BBB:2"><ZoneGroupMember UUID="RINCON_BBB" Location="%[2]s/xml/device_description.xml" ZoneName="Office"/></ZoneGroup>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			testSubscribeGroup(t, groups)
+		})
+	}
+}
+
+func testSubscribeGroup(t *testing.T, groups string) {
+	kitchen := newFakeDevice(t, nil)
+	office := newFakeDevice(t, nil)
+	office.description = mockDeviceDescriptionFor("Office", "RINCON_BBB")
+
+	kzp := kitchen.ZonePlayer(t)
+	ozp := office.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+	m := NewSubscriptionManager(s)
+
+	var mu sync.Mutex
+	var events []interface{}
+	g := &Group{ID: "RINCON_000E58CDCA4001400:1", Coordinator: kzp, Members: []*ZonePlayer{kzp, ozp}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	gs, err := m.SubscribeGroup(ctx, g, func(evt interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, evt)
+	})
+	if err != nil {
+		t.Fatalf("SubscribeGroup failed: %v", err)
+	}
+	if len(kitchen.Sids()) != 3 {
+		t.Fatalf("Expected AVTransport, GroupRenderingControl and ZoneGroupTopology subscriptions, got %v", kitchen.Sids())
+	}
+
+	topology := `<ZoneGroupState><ZoneGroups>` + fmt.Sprintf(groups, kitchen.URL(), office.URL()) + `</ZoneGroups></ZoneGroupState>`
+	body := `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><ZoneGroupState>` + html.EscapeString(topology) + `</ZoneGroupState></e:property></e:propertyset>`
+	status, err := kitchen.Notify(sidFor(t, kitchen, "/ZoneGroupTopology/Event"), body)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Notify failed: %d %v", status, err)
+	}
+
+	waitUntil(t, "subscriptions to move", func() bool {
+		return len(office.Sids()) == 2 && len(kitchen.Sids()) == 1
+	})
+	if gs.Coordinator().UUID() != "RINCON_BBB" {
+		t.Errorf("Expected Office to coordinate, got %s", gs.Coordinator().RoomName())
+	}
+
+	status, err = office.Notify(sidFor(t, office, "/MediaRenderer/AVTransport/Event"), lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/></InstanceID></Event>`))
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Notify failed: %d %v", status, err)
+	}
+
+	mu.Lock()
+	if len(events) != 2 {
+		t.Fatalf("Expected the change and the event of the new coordinator, got %v", events)
+	}
+	if e, ok := events[0].(CoordinatorChangeEvent); !ok || e.Previous != kzp || e.Coordinator.UUID() != "RINCON_BBB" || e.GroupID != "RINCON_BBB:2" {
+		t.Errorf("Unexpected change %+v", events[0])
+	}
+	if e, ok := events[1].(AVTransportLastChange); !ok || e.InstanceID.TransportState.Value != "PLAYING" {
+		t.Errorf("Unexpected event %+v", events[1])
+	}
+	mu.Unlock()
+
+	if err := gs.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if len(office.Sids()) != 0 || len(kitchen.Sids()) != 0 {
+		t.Errorf("Expected every subscription to be gone, got %v %v", kitchen.Sids(), office.Sids())
+	}
+}
+
+// sidFor returns the SID of the subscription of the device to the service with the event path.
+func sidFor(t *testing.T, d *fakeDevice, path string) string {
+	t.Helper()
+	for _, sid := range d.Sids() {
+		if strings.Contains(d.Callback(sid), path+"?") {
+			return sid
+		}
+	}
+	t.Fatalf("No subscription to %s", path)
+	return ""
+}
@@ -0,0 +1,203 @@
+package sonos
+
+import (
+	"context"
+	"fmt"
+
+	dev "github.com/caglar10ur/sonos/services/DeviceProperties"
+)
+
+// Model numbers which can act as the center of a home theater: the Playbar, Playbase, Beam,
+// Beam (Gen 2), Arc, Ray and Amp
+var homeTheaterModels = []string{"S9", "S11", "S14", "S31", "S19", "S36", "S16"}
+
+// Model number every generation of the Sub reports
+const subwooferModel = "Sub"
+
+// HomeTheater is the surround layout of a room built around a soundbar.
+type HomeTheater struct {
+	RoomName   string
+	Soundbar   string
+	Subwoofers []string
+	LeftRear   string
+	RightRear  string
+	ChannelMap ChannelMap
+}
+
+// HasSurrounds reports whether both rear satellites are bonded.
+func (h *HomeTheater) HasSurrounds() bool {
+	return h.LeftRear != "" && h.RightRear != ""
+}
+
+// homeTheaterFromChannelMap returns the home theater described by the channel map, if any.
+func homeTheaterFromChannelMap(m ChannelMap) (HomeTheater, bool) {
+	var ht HomeTheater
+	for _, e := range m {
+		switch {
+		case e.Has(ChannelLeftFront) && e.Has(ChannelRightFront):
+			ht.Soundbar = e.UUID
+		case e.Has(ChannelSubwoofer):
+			ht.Subwoofers = append(ht.Subwoofers, e.UUID)
+		case e.Has(ChannelLeftRear):
+			ht.LeftRear = e.UUID
+		case e.Has(ChannelRightRear):
+			ht.RightRear = e.UUID
+		}
+	}
+	if ht.Soundbar == "" {
+		return HomeTheater{}, false
+	}
+	ht.ChannelMap = m
+	return ht, true
+}
+
+// Channels returns the channels the satellite plays.
+func (s *Satellite) Channels() []Channel {
+	m, err := ParseChannelMap(s.HTSatChanMapSet)
+	if err != nil {
+		return nil
+	}
+	if e := m.Entry(s.UUID); e != nil {
+		return e.Channels
+	}
+	return nil
+}
+
+// HomeTheaters returns the home theater layout of every room parsed from the HTSatChanMapSet of the players.
+func (z *ZonePlayer) HomeTheaters() ([]HomeTheater, error) {
+	zoneGroupState, err := z.GetZoneGroupState()
+	if err != nil {
+		return nil, err
+	}
+	return homeTheaters(zoneGroupState), nil
+}
+
+func homeTheaters(zoneGroupState *ZoneGroupState) []HomeTheater {
+	var hts []HomeTheater
+	for _, group := range zoneGroupState.ZoneGroups {
+		for _, member := range group.ZoneGroupMember {
+			if member.HTSatChanMapSet == "" {
+				continue
+			}
+			m, err := ParseChannelMap(member.HTSatChanMapSet)
+			if err != nil {
+				continue
+			}
+			ht, ok := homeTheaterFromChannelMap(m)
+			if !ok || ht.Soundbar != member.UUID {
+				continue
+			}
+			ht.RoomName = member.ZoneName
+			hts = append(hts, ht)
+		}
+	}
+	return hts
+}
+
+// homeTheater returns the layout of the ZonePlayer's room from the given topology, if any.
+func (z *ZonePlayer) homeTheater(zoneGroupState *ZoneGroupState) *HomeTheater {
+	for _, ht := range homeTheaters(zoneGroupState) {
+		if ht.Soundbar == z.UUID() {
+			return &ht
+		}
+	}
+	return nil
+}
+
+// AddSurrounds bonds the given players as the left and right rear satellites of the ZonePlayer,
+// which has to be a soundbar. It returns once the topology reflects the new layout.
+func (z *ZonePlayer) AddSurrounds(ctx context.Context, left, right *ZonePlayer, progress BondingProgressFunc) error {
+	if !isModelNumber(z, homeTheaterModels...) {
+		return fmt.Errorf("%s does not support surrounds", z.ModelName())
+	}
+	if left.UUID() == right.UUID() {
+		return fmt.Errorf("can't use %s as both surrounds", left.RoomName())
+	}
+	for _, zp := range []*ZonePlayer{left, right} {
+		if isModelNumber(zp, subwooferModel) || isModelNumber(zp, homeTheaterModels...) {
+			return fmt.Errorf("%s can't be used as a surround", zp.ModelName())
+		}
+	}
+
+	return z.addHTSatellites(ctx, ChannelMap{
+		{UUID: z.UUID(), Channels: []Channel{ChannelLeftFront, ChannelRightFront}},
+		{UUID: left.UUID(), Channels: []Channel{ChannelLeftRear}},
+		{UUID: right.UUID(), Channels: []Channel{ChannelRightRear}},
+	}, progress)
+}
+
+// AddSubwoofer bonds the given player as a subwoofer of the ZonePlayer, which has to be a soundbar.
+// It returns once the topology reflects the new layout.
+func (z *ZonePlayer) AddSubwoofer(ctx context.Context, sub *ZonePlayer, progress BondingProgressFunc) error {
+	if !isModelNumber(z, homeTheaterModels...) {
+		return fmt.Errorf("%s does not support subwoofers", z.ModelName())
+	}
+	if !isModelNumber(sub, subwooferModel) {
+		return fmt.Errorf("%s is not a subwoofer", sub.ModelName())
+	}
+
+	return z.addHTSatellites(ctx, ChannelMap{
+		{UUID: z.UUID(), Channels: []Channel{ChannelLeftFront, ChannelRightFront}},
+		{UUID: sub.UUID(), Channels: []Channel{ChannelSubwoofer}},
+	}, progress)
+}
+
+func (z *ZonePlayer) addHTSatellites(ctx context.Context, m ChannelMap, progress BondingProgressFunc) error {
+	if _, err := z.DeviceProperties.AddHTSatellite(&dev.AddHTSatelliteArgs{HTSatChanMapSet: m.String()}); err != nil {
+		return err
+	}
+
+	return z.waitForTopology(ctx, func(zoneGroupState *ZoneGroupState) bool {
+		ht := z.homeTheater(zoneGroupState)
+		if ht == nil {
+			return false
+		}
+		for _, e := range m {
+			if ht.ChannelMap.Entry(e.UUID) == nil {
+				return false
+			}
+		}
+		return true
+	}, progress)
+}
+
+// RemoveSatellite unbonds the satellite with the given UUID, a surround or a subwoofer, from the
+// ZonePlayer's home theater. It returns once the topology reflects the new layout.
+func (z *ZonePlayer) RemoveSatellite(ctx context.Context, uuid string, progress BondingProgressFunc) error {
+	zoneGroupState, err := z.GetZoneGroupState()
+	if err != nil {
+		return err
+	}
+	ht := z.homeTheater(zoneGroupState)
+	if ht == nil || uuid == z.UUID() || ht.ChannelMap.Entry(uuid) == nil {
+		return fmt.Errorf("%s is not a satellite of %s", uuid, z.RoomName())
+	}
+
+	if _, err := z.DeviceProperties.RemoveHTSatellite(&dev.RemoveHTSatelliteArgs{SatRoomUUID: uuid}); err != nil {
+		return err
+	}
+
+	return z.waitForTopology(ctx, func(zoneGroupState *ZoneGroupState) bool {
+		ht := z.homeTheater(zoneGroupState)
+		return ht == nil || ht.ChannelMap.Entry(uuid) == nil
+	}, progress)
+}
+
+// RemoveSurrounds unbonds both rear satellites from the ZonePlayer's home theater.
+func (z *ZonePlayer) RemoveSurrounds(ctx context.Context, progress BondingProgressFunc) error {
+	zoneGroupState, err := z.GetZoneGroupState()
+	if err != nil {
+		return err
+	}
+	ht := z.homeTheater(zoneGroupState)
+	if ht == nil || !ht.HasSurrounds() {
+		return fmt.Errorf("%s has no surrounds", z.RoomName())
+	}
+
+	for _, uuid := range []string{ht.LeftRear, ht.RightRear} {
+		if err := z.RemoveSatellite(ctx, uuid, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
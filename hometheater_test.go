@@ -0,0 +1,160 @@
+package sonos
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestHomeTheaters(t *testing.T) {
+	raw := `<ZoneGroupState><ZoneGroups><ZoneGroup Coordinator="RINCON_SB" ID="RINCON_SB:1"><ZoneGroupMember UUID="RINCON_SB" ZoneName="Living Room" HTSatChanMapSet="RINCON_SB:LF,RF;RINCON_SUB:SW;RINCON_L:LR;RINCON_R:RR"><Satellite UUID="RINCON_SUB" ZoneName="Living Room" HTSatChanMapSet="RINCON_SB:LF,RF;RINCON_SUB:SW;RINCON_L:LR;RINCON_R:RR" /><Satellite UUID="RINCON_L" ZoneName="Living Room" HTSatChanMapSet="RINCON_SB:LF,RF;RINCON_SUB:SW;RINCON_L:LR;RINCON_R:RR" /><Satellite UUID="RINCON_R" ZoneName="Living Room" HTSatChanMapSet="RINCON_SB:LF,RF;RINCON_SUB:SW;RINCON_L:LR;RINCON_R:RR" /></ZoneGroupMember></ZoneGroup></ZoneGroups></ZoneGroupState>`
+
+	var zoneGroupState ZoneGroupState
+	if err := xml.Unmarshal([]byte(raw), &zoneGroupState); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	hts := homeTheaters(&zoneGroupState)
+	if len(hts) != 1 {
+		t.Fatalf("Expected 1 home theater, got %d", len(hts))
+	}
+	ht := hts[0]
+	if ht.RoomName != "Living Room" || ht.Soundbar != "RINCON_SB" || ht.LeftRear != "RINCON_L" || ht.RightRear != "RINCON_R" || len(ht.Subwoofers) != 1 || ht.Subwoofers[0] != "RINCON_SUB" {
+		t.Errorf("Unexpected home theater: %+v", ht)
+	}
+
+	satellites := zoneGroupState.ZoneGroups[0].ZoneGroupMember[0].Satellite
+	if len(satellites) != 3 {
+		t.Fatalf("Expected 3 satellites, got %d", len(satellites))
+	}
+	if channels := satellites[1].Channels(); len(channels) != 1 || channels[0] != ChannelLeftRear {
+		t.Errorf("Expected LR, got %v", channels)
+	}
+}
+
+func TestAddSubwoofer(t *testing.T) {
+	before := `&lt;ZoneGroupState&gt;&lt;ZoneGroups&gt;&lt;ZoneGroup Coordinator=&quot;RINCON_000E58CDCA4001400&quot; ID=&quot;RINCON_000E58CDCA4001400:1&quot;&gt;&lt;ZoneGroupMember UUID=&quot;RINCON_000E58CDCA4001400&quot; Location=&quot;http://192.168.1.100:1400/xml/device_description.xml&quot; ZoneName=&quot;Kitchen&quot; /&gt;&lt;/ZoneGroup&gt;&lt;/ZoneGroups&gt;&lt;/ZoneGroupState&gt;`
+	after := `&lt;ZoneGroupState&gt;&lt;ZoneGroups&gt;&lt;ZoneGroup Coordinator=&quot;RINCON_000E58CDCA4001400&quot; ID=&quot;RINCON_000E58CDCA4001400:1&quot;&gt;&lt;ZoneGroupMember UUID=&quot;RINCON_000E58CDCA4001400&quot; Location=&quot;http://192.168.1.100:1400/xml/device_description.xml&quot; ZoneName=&quot;Kitchen&quot; HTSatChanMapSet=&quot;RINCON_000E58CDCA4001400:LF,RF;RINCON_SUB:SW&quot;&gt;&lt;Satellite UUID=&quot;RINCON_SUB&quot; ZoneName=&quot;Kitchen&quot; HTSatChanMapSet=&quot;RINCON_000E58CDCA4001400:LF,RF;RINCON_SUB:SW&quot; /&gt;&lt;/ZoneGroupMember&gt;&lt;/ZoneGroup&gt;&lt;/ZoneGroups&gt;&lt;/ZoneGroupState&gt;`
+
+	var added bool
+	handlers := map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:ZoneGroupTopology:1#GetZoneGroupState": func(req *http.Request) (*http.Response, error) {
+			state := before
+			if added {
+				state = after
+			}
+			return mockResponseHandler("ZoneGroupTopology", "GetZoneGroupState", "<ZoneGroupState>"+state+"</ZoneGroupState>")(req)
+		},
+		"urn:schemas-upnp-org:service:DeviceProperties:1#AddHTSatellite": func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			if !bytes.Contains(body, []byte("<HTSatChanMapSet>RINCON_000E58CDCA4001400:LF,RF;RINCON_SUB:SW</HTSatChanMapSet>")) {
+				t.Errorf("Unexpected AddHTSatellite request: %s", string(body))
+			}
+			added = true
+			return mockSuccessHandler("DeviceProperties", "AddHTSatellite")(req)
+		},
+	}
+
+	mockClient := &http.Client{
+		Transport: &MockRoundTripper{
+			Handlers: handlers,
+			Descriptions: map[string]string{
+				"192.168.1.100:1400": withModel(mockDeviceDescription, "Sonos Beam", "S14"),
+				"192.168.1.101:1400": withModel(mockDeviceDescriptionFor("Kitchen", "RINCON_SUB"), "Sonos Sub", "Sub"),
+			},
+		},
+	}
+	loc, _ := url.Parse("http://192.168.1.100:1400/xml/device_description.xml")
+	soundbar, err := NewZonePlayer(WithClient(mockClient), WithLocation(loc))
+	if err != nil {
+		t.Fatalf("Failed to create soundbar: %v", err)
+	}
+	sub, err := soundbar.zonePlayerAt("RINCON_SUB", "http://192.168.1.101:1400/xml/device_description.xml")
+	if err != nil {
+		t.Fatalf("Failed to create sub: %v", err)
+	}
+
+	if err := soundbar.AddSubwoofer(context.Background(), soundbar, nil); err == nil {
+		t.Error("Expected adding a soundbar as a subwoofer to fail")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := soundbar.AddSubwoofer(ctx, sub, nil); err != nil {
+		t.Fatalf("AddSubwoofer failed: %v", err)
+	}
+
+	hts, err := soundbar.HomeTheaters()
+	if err != nil {
+		t.Fatalf("HomeTheaters failed: %v", err)
+	}
+	if len(hts) != 1 || len(hts[0].Subwoofers) != 1 || hts[0].Subwoofers[0] != "RINCON_SUB" {
+		t.Errorf("Unexpected home theaters: %+v", hts)
+	}
+}
+
+func TestRemoveSatelliteEvents(t *testing.T) {
+	// Only the topology events report the removal
+	interval := topologyPollInterval
+	topologyPollInterval = time.Hour
+	defer func() { topologyPollInterval = interval }()
+
+	before := `<ZoneGroupState><ZoneGroups><ZoneGroup Coordinator="RINCON_000E58CDCA4001400" ID="RINCON_000E58CDCA4001400:1"><ZoneGroupMember UUID="RINCON_000E58CDCA4001400" ZoneName="Kitchen" HTSatChanMapSet="RINCON_000E58CDCA4001400:LF,RF;RINCON_SUB:SW"><Satellite UUID="RINCON_SUB" ZoneName="Kitchen" HTSatChanMapSet="RINCON_000E58CDCA4001400:LF,RF;RINCON_SUB:SW"/></ZoneGroupMember></ZoneGroup></ZoneGroups></ZoneGroupState>`
+	after := `<ZoneGroupState><ZoneGroups><ZoneGroup Coordinator="RINCON_000E58CDCA4001400" ID="RINCON_000E58CDCA4001400:1"><ZoneGroupMember UUID="RINCON_000E58CDCA4001400" ZoneName="Kitchen"/></ZoneGroup><ZoneGroup Coordinator="RINCON_SUB" ID="RINCON_SUB:1"><ZoneGroupMember UUID="RINCON_SUB" ZoneName="Sub"/></ZoneGroup></ZoneGroups></ZoneGroupState>`
+
+	pending := make(chan struct{})
+	device := newFakeDevice(t, map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:ZoneGroupTopology:1#GetZoneGroupState": mockResponseHandler("ZoneGroupTopology", "GetZoneGroupState", "<ZoneGroupState>"+escapeXML(before)+"</ZoneGroupState>"),
+		"urn:schemas-upnp-org:service:DeviceProperties:1#RemoveHTSatellite":  mockSuccessHandler("DeviceProperties", "RemoveHTSatellite"),
+	})
+	device.description = withModel(mockDeviceDescription, "Sonos Beam", "S14")
+	soundbar := device.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sid, err := s.Subscribe(ctx, &SubscriptionOptions{ZonePlayer: soundbar, Service: soundbar.ZoneGroupTopology})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	// Keep reporting the removal until it was seen
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		<-pending
+		body := `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><ZoneGroupState>` + html.EscapeString(after) + `</ZoneGroupState></e:property></e:propertyset>`
+		for {
+			_, _ = device.Notify(sid, body)
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}()
+
+	var stages []BondingStage
+	if err := soundbar.RemoveSatellite(ctx, "RINCON_SUB", func(p BondingProgress) {
+		stages = append(stages, p.Stage)
+		if p.Stage == BondingPending {
+			close(pending)
+		}
+	}); err != nil {
+		t.Fatalf("RemoveSatellite failed: %v", err)
+	}
+	if len(stages) != 3 || stages[2] != BondingCompleted {
+		t.Errorf("Expected the removal to be completed, got %v", stages)
+	}
+}
@@ -0,0 +1,188 @@
+package sonos
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"sync"
+	"time"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+	clk "github.com/caglar10ur/sonos/services/AlarmClock"
+	ain "github.com/caglar10ur/sonos/services/AudioIn"
+	con "github.com/caglar10ur/sonos/services/ConnectionManager"
+	dir "github.com/caglar10ur/sonos/services/ContentDirectory"
+	dev "github.com/caglar10ur/sonos/services/DeviceProperties"
+	gmn "github.com/caglar10ur/sonos/services/GroupManagement"
+	rcg "github.com/caglar10ur/sonos/services/GroupRenderingControl"
+	mus "github.com/caglar10ur/sonos/services/MusicServices"
+	ply "github.com/caglar10ur/sonos/services/QPlay"
+	que "github.com/caglar10ur/sonos/services/Queue"
+	ren "github.com/caglar10ur/sonos/services/RenderingControl"
+	sys "github.com/caglar10ur/sonos/services/SystemProperties"
+	vli "github.com/caglar10ur/sonos/services/VirtualLineIn"
+	zgt "github.com/caglar10ur/sonos/services/ZoneGroupTopology"
+)
+
+// JournalEntry is a line of the journal written by WithJournal.
+type JournalEntry struct {
+	Time time.Time `json:"time"`
+	UUID string    `json:"uuid"`
+	Room string    `json:"room"`
+	// Name of the service package, e.g. AVTransport
+	Service string `json:"service"`
+	Sid     string `json:"sid"`
+	// Empty for events which weren't sent by the device, e.g. ResyncEvent
+	Seq string `json:"seq,omitempty"`
+	// Go type of the event, e.g. sonos.AVTransportLastChange
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Event returns the event of the entry, a RawEvent if its type is unknown.
+func (e *JournalEntry) Event() (interface{}, error) {
+	t, ok := journalTypes[e.Type]
+	if !ok {
+		return RawEvent{Service: e.Service, Name: e.Type, Value: string(e.Payload)}, nil
+	}
+
+	v := reflect.New(t)
+	if err := json.Unmarshal(e.Payload, v.Interface()); err != nil {
+		return nil, fmt.Errorf("%s: %w", e.Type, err)
+	}
+	evt := v.Elem().Interface()
+
+	// JSON doesn't tell integers and floats apart
+	if l, ok := evt.(LastChangeEvent); ok {
+		types := lastChangeTypes[l.Service]
+		for _, instance := range l.Instances {
+			for i, value := range instance.Values {
+				instance.Values[i].Value = typedValue(types[value.Name], value.Raw)
+			}
+		}
+	}
+	return evt, nil
+}
+
+// journalTypes maps the type names recorded in the journal to the types of the events.
+var journalTypes = func() map[string]reflect.Type {
+	types := make(map[string]reflect.Type)
+	for _, evt := range []interface{}{
+		AVTransportLastChange{},
+		RenderingControlLastChange{},
+		QueueLastChange{},
+		ZoneGroupTopologyZoneGroupState{},
+		ZoneGroupTopologyAvailableSoftwareUpdate{},
+		LastChangeEvent{},
+		ResyncEvent{},
+		SleepTimerEvent{},
+		RawEvent{},
+	} {
+		t := reflect.TypeOf(evt)
+		types[t.String()] = t
+	}
+
+	// The state variables of the services
+	for _, property := range []interface{}{
+		avt.Property{}, clk.Property{}, ain.Property{}, con.Property{}, dir.Property{},
+		dev.Property{}, gmn.Property{}, rcg.Property{}, mus.Property{}, ply.Property{},
+		que.Property{}, ren.Property{}, sys.Property{}, vli.Property{}, zgt.Property{},
+	} {
+		t := reflect.TypeOf(property)
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i).Type; f.Kind() == reflect.Pointer {
+				types[f.Elem().String()] = f.Elem()
+			}
+		}
+	}
+	return types
+}()
+
+type journal struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newJournalEntry(opts *SubscriptionOptions, sid, seq string, evt interface{}) (JournalEntry, error) {
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	return JournalEntry{
+		Time:    time.Now(),
+		UUID:    opts.ZonePlayer.UUID(),
+		Room:    opts.ZonePlayer.RoomName(),
+		Service: path.Base(reflect.TypeOf(opts.Service).Elem().PkgPath()),
+		Sid:     sid,
+		Seq:     seq,
+		Type:    fmt.Sprintf("%T", evt),
+		Payload: payload,
+	}, nil
+}
+
+func (j *journal) record(entry JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.enc.Encode(entry)
+}
+
+type ReplayOption func(*replayOptions)
+
+type replayOptions struct {
+	speed float64
+}
+
+// WithSpeed sets how many times faster than recorded the events are replayed, zero replays them
+// without waiting. Events are replayed at the recorded pace by default.
+func WithSpeed(speed float64) ReplayOption {
+	return func(o *replayOptions) {
+		o.speed = speed
+	}
+}
+
+// Replay passes the events of a journal written by WithJournal to fn, waiting between them as
+// long as they were apart when they were recorded.
+func Replay(ctx context.Context, r io.Reader, fn EventHandlerFunc, opts ...ReplayOption) error {
+	o := replayOptions{speed: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	scanner := bufio.NewScanner(r)
+	// Entries carry whole event notifications
+	scanner.Buffer(make([]byte, 0, 64*1024), 2*maxEventSize)
+
+	var previous time.Time
+	for line := 1; scanner.Scan(); line++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if o.speed > 0 && !previous.IsZero() {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(float64(entry.Time.Sub(previous)) / o.speed)):
+			}
+		}
+		previous = entry.Time
+
+		evt, err := entry.Event()
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		fn(evt)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,102 @@
+package sonos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	rcg "github.com/caglar10ur/sonos/services/GroupRenderingControl"
+)
+
+func TestJournalReplay(t *testing.T) {
+	device := newFakeDevice(t, nil)
+	device.initialEvents = map[string]string{
+		"/MediaRenderer/AVTransport/Event":           lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/><CurrentTrack val="2"/></InstanceID></Event>`),
+		"/MediaRenderer/GroupRenderingControl/Event": `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><GroupVolume>30</GroupVolume></e:property></e:propertyset>`,
+	}
+	zp := device.ZonePlayer(t)
+
+	var journal bytes.Buffer
+	s, err := NewSonos(WithJournal(&journal))
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	var recorded []interface{}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, service := range []SonosService{zp.AVTransport, zp.GroupRenderingControl} {
+		opts := &SubscriptionOptions{ZonePlayer: zp, Service: service, EventHandler: func(evt interface{}) {
+			recorded = append(recorded, evt)
+		}}
+		if _, err := s.Subscribe(ctx, opts); err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(journal.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected two entries, got %s", journal.String())
+	}
+	var entry JournalEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if entry.UUID != "RINCON_000E58CDCA4001400" || entry.Room != "Kitchen" || entry.Service != "AVTransport" || entry.Seq != "0" || entry.Sid == "" || entry.Type != "sonos.AVTransportLastChange" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	var replayed []interface{}
+	if err := Replay(ctx, &journal, func(evt interface{}) {
+		replayed = append(replayed, evt)
+	}, WithSpeed(0)); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(replayed) != 2 {
+		t.Fatalf("Expected two events, got %v", replayed)
+	}
+	e, ok := replayed[0].(AVTransportLastChange)
+	if !ok {
+		t.Fatalf("Expected an AVTransportLastChange, got %T", replayed[0])
+	}
+	if c := e.Typed(); c.TransportState != "PLAYING" || c.CurrentTrack != 2 || !c.Has("CurrentTrack") || c.Has("NextTrackURI") {
+		t.Errorf("Unexpected replayed change %+v", c)
+	}
+	if v, ok := replayed[1].(rcg.GroupVolume); !ok || v != recorded[1] {
+		t.Errorf("Expected %v, got %#v", recorded[1], replayed[1])
+	}
+}
+
+func TestReplaySpeed(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var journal bytes.Buffer
+	enc := json.NewEncoder(&journal)
+	for i, payload := range []string{`10`, `20`, `{"unknown":true}`} {
+		typ := "grouprenderingcontrol.GroupVolume"
+		if i == 2 {
+			typ = "sonos.FutureEvent"
+		}
+		enc.Encode(JournalEntry{Time: start.Add(time.Duration(i) * time.Second), Type: typ, Service: "GroupRenderingControl", Payload: json.RawMessage(payload)})
+	}
+
+	var events []interface{}
+	began := time.Now()
+	if err := Replay(context.Background(), &journal, func(evt interface{}) {
+		events = append(events, evt)
+	}, WithSpeed(100)); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if elapsed := time.Since(began); elapsed < 20*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected two seconds replayed 100 times faster, took %s", elapsed)
+	}
+	if v, ok := events[1].(rcg.GroupVolume); !ok || v != 20 {
+		t.Errorf("Unexpected event %#v", events[1])
+	}
+	if raw, ok := events[2].(RawEvent); !ok || raw.Name != "sonos.FutureEvent" || raw.Value != `{"unknown":true}` {
+		t.Errorf("Expected unknown events to be replayed as RawEvent, got %#v", events[2])
+	}
+}
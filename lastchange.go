@@ -0,0 +1,572 @@
+package sonos
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caglar10ur/sonos/didl"
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+	rcg "github.com/caglar10ur/sonos/services/GroupRenderingControl"
+	que "github.com/caglar10ur/sonos/services/Queue"
+	ren "github.com/caglar10ur/sonos/services/RenderingControl"
+	vli "github.com/caglar10ur/sonos/services/VirtualLineIn"
+)
+
+// UnmarshalXML records which elements the event carried besides decoding them.
+func (i *AVTransportInstanceID) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain AVTransportInstanceID
+	var aux struct {
+		plain
+		Inner []byte `xml:",innerxml"`
+	}
+	if err := d.DecodeElement(&aux, &start); err != nil {
+		return err
+	}
+	*i = AVTransportInstanceID(aux.plain)
+	i.present = elementNames(aux.Inner)
+	return nil
+}
+
+// UnmarshalXML records which elements the event carried besides decoding them.
+func (i *RenderingControlInstanceID) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain RenderingControlInstanceID
+	var aux struct {
+		plain
+		Any []struct {
+			XMLName xml.Name
+			Channel string `xml:"channel,attr"`
+			Value   string `xml:"val,attr"`
+		} `xml:",any"`
+		Inner []byte `xml:",innerxml"`
+	}
+	if err := d.DecodeElement(&aux, &start); err != nil {
+		return err
+	}
+	*i = RenderingControlInstanceID(aux.plain)
+	i.present = elementNames(aux.Inner)
+
+	// Keep what newer firmware sends until it gets a field
+	for _, e := range aux.Any {
+		if i.Unknown == nil {
+			i.Unknown = make(map[string]string)
+		}
+		key := e.XMLName.Local
+		if e.Channel != "" {
+			key += "/" + e.Channel
+		}
+		i.Unknown[key] = e.Value
+	}
+	return nil
+}
+
+// MarshalJSON keeps which elements the event carried.
+func (i AVTransportInstanceID) MarshalJSON() ([]byte, error) {
+	type plain AVTransportInstanceID
+	return json.Marshal(struct {
+		plain
+		Present []string `json:",omitempty"`
+	}{plain(i), presentNames(i.present)})
+}
+
+// UnmarshalJSON restores which elements the event carried.
+func (i *AVTransportInstanceID) UnmarshalJSON(data []byte) error {
+	type plain AVTransportInstanceID
+	var aux struct {
+		plain
+		Present []string
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*i = AVTransportInstanceID(aux.plain)
+	i.present = presentSet(aux.Present)
+	return nil
+}
+
+// MarshalJSON keeps which elements the event carried.
+func (i RenderingControlInstanceID) MarshalJSON() ([]byte, error) {
+	type plain RenderingControlInstanceID
+	return json.Marshal(struct {
+		plain
+		Present []string `json:",omitempty"`
+	}{plain(i), presentNames(i.present)})
+}
+
+// UnmarshalJSON restores which elements the event carried.
+func (i *RenderingControlInstanceID) UnmarshalJSON(data []byte) error {
+	type plain RenderingControlInstanceID
+	var aux struct {
+		plain
+		Present []string
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*i = RenderingControlInstanceID(aux.plain)
+	i.present = presentSet(aux.Present)
+	return nil
+}
+
+// presentNames returns the sorted names of the present elements.
+func presentNames(present map[string]bool) []string {
+	if present == nil {
+		return nil
+	}
+	names := make([]string, 0, len(present))
+	for name := range present {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func presentSet(names []string) map[string]bool {
+	if names == nil {
+		return nil
+	}
+	present := make(map[string]bool, len(names))
+	for _, name := range names {
+		present[name] = true
+	}
+	return present
+}
+
+// elementNames returns the local names of the top level elements of the XML fragment.
+func elementNames(inner []byte) map[string]bool {
+	names := make(map[string]bool)
+	d := xml.NewDecoder(bytes.NewReader(inner))
+	depth := 0
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return names
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				names[t.Name.Local] = true
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// AVTransportChange is the typed projection of an AVTransport LastChange event.
+//
+// LastChange events are partial updates, Has reports which fields the event carried.
+type AVTransportChange struct {
+	TransportState               avt.TransportStateEnum
+	TransportStatus              string
+	TransportPlaySpeed           string
+	CurrentPlayMode              avt.CurrentPlayModeEnum
+	CurrentCrossfadeMode         bool
+	NumberOfTracks               int
+	CurrentTrack                 int
+	CurrentSection               int
+	CurrentTrackURI              string
+	CurrentTrackDuration         time.Duration
+	CurrentTrackMetaData         *didl.Item
+	NextTrackURI                 string
+	NextTrackMetaData            *didl.Item
+	EnqueuedTransportURI         string
+	EnqueuedTransportURIMetaData *didl.Item
+	PlaybackStorageMedium        string
+	AVTransportURI               string
+	AVTransportURIMetaData       *didl.Item
+	NextAVTransportURI           string
+	NextAVTransportURIMetaData   *didl.Item
+	CurrentTransportActions      []string
+	CurrentValidPlayModes        []string
+	CurrentMediaDuration         time.Duration
+	SleepTimerGeneration         int
+	AlarmRunning                 bool
+	SnoozeRunning                bool
+	RestartPending               bool
+	DirectControlClientID        string
+	DirectControlIsSuspended     bool
+	DirectControlAccountID       string
+
+	present map[string]bool
+}
+
+// Has reports whether the event carried the field with the given name, e.g. "TransportState".
+func (c *AVTransportChange) Has(field string) bool {
+	return c.present[field]
+}
+
+// Typed returns the typed projection of the event.
+func (e *AVTransportLastChange) Typed() AVTransportChange {
+	i := &e.InstanceID
+	return AVTransportChange{
+		TransportState:               avt.TransportStateEnum(i.TransportState.Value),
+		TransportStatus:              i.TransportStatus.Value,
+		TransportPlaySpeed:           i.TransportPlaySpeed.Value,
+		CurrentPlayMode:              avt.CurrentPlayModeEnum(i.CurrentPlayMode.Value),
+		CurrentCrossfadeMode:         parseBool(i.CurrentCrossfadeMode.Value),
+		NumberOfTracks:               parseInt(i.NumberOfTracks.Value),
+		CurrentTrack:                 parseInt(i.CurrentTrack.Value),
+		CurrentSection:               parseInt(i.CurrentSection.Value),
+		CurrentTrackURI:              i.CurrentTrackURI.Value,
+		CurrentTrackDuration:         parseDuration(i.CurrentTrackDuration.Value),
+		CurrentTrackMetaData:         parseItem(i.CurrentTrackMetaData.Value),
+		NextTrackURI:                 i.NextTrackURI.Value,
+		NextTrackMetaData:            parseItem(i.NextTrackMetaData.Value),
+		EnqueuedTransportURI:         i.EnqueuedTransportURI.Value,
+		EnqueuedTransportURIMetaData: parseItem(i.EnqueuedTransportURIMetaData.Value),
+		PlaybackStorageMedium:        i.PlaybackStorageMedium.Value,
+		AVTransportURI:               i.AVTransportURI.Value,
+		AVTransportURIMetaData:       parseItem(i.AVTransportURIMetaData.Value),
+		NextAVTransportURI:           i.NextAVTransportURI.Value,
+		NextAVTransportURIMetaData:   parseItem(i.NextAVTransportURIMetaData.Value),
+		CurrentTransportActions:      parseList(i.CurrentTransportActions.Value),
+		CurrentValidPlayModes:        parseList(i.CurrentValidPlayModes.Value),
+		CurrentMediaDuration:         parseDuration(i.CurrentMediaDuration.Value),
+		SleepTimerGeneration:         parseInt(i.SleepTimerGeneration.Value),
+		AlarmRunning:                 parseBool(i.AlarmRunning.Value),
+		SnoozeRunning:                parseBool(i.SnoozeRunning.Value),
+		RestartPending:               parseBool(i.RestartPending.Value),
+		DirectControlClientID:        i.DirectControlClientID.Value,
+		DirectControlIsSuspended:     parseBool(i.DirectControlIsSuspended.Value),
+		DirectControlAccountID:       i.DirectControlAccountID.Value,
+		present:                      i.present,
+	}
+}
+
+// RenderingControlChange is the typed projection of a RenderingControl LastChange event.
+// Values which can be set per channel hold the Master value, the maps hold every channel.
+//
+// LastChange events are partial updates, Has reports which fields the event carried.
+type RenderingControlChange struct {
+	// Keyed by channel, e.g. Master, LF or RF
+	Volume map[string]int
+	// Keyed by channel, in 1/256 dB
+	VolumeDB map[string]int
+	// Keyed by channel, e.g. Master, LF or RF
+	SubGains map[string]int
+	// Keyed by channel, e.g. Master, LF or RF
+	Mute                      map[string]bool
+	Bass                      int
+	Treble                    int
+	Loudness                  bool
+	OutputFixed               bool
+	HeadphoneConnected        bool
+	SpeakerSize               int
+	SubGain                   int
+	SubCrossover              int
+	SubPolarity               int
+	SubEnabled                bool
+	SonarEnabled              bool
+	SonarCalibrationAvailable bool
+	PresetNameList            []string
+	DialogLevel               bool
+	SpeechEnhanceEnabled      bool
+	NightMode                 bool
+	SurroundEnabled           bool
+	SurroundMode              int
+	SurroundLevel             int
+	MusicSurroundLevel        int
+	AudioDelay                int
+	AudioDelayLeftRear        int
+	AudioDelayRightRear       int
+	HeightChannelLevel        int
+	// Elements without a field, see RenderingControlInstanceID.Unknown
+	Unknown map[string]string
+
+	present map[string]bool
+}
+
+// Has reports whether the event carried the field with the given name, e.g. "Volume".
+func (c *RenderingControlChange) Has(field string) bool {
+	return c.present[field]
+}
+
+// Typed returns the typed projection of the event.
+func (e *RenderingControlLastChange) Typed() RenderingControlChange {
+	i := &e.InstanceID
+	c := RenderingControlChange{
+		Volume:                    make(map[string]int),
+		VolumeDB:                  make(map[string]int),
+		Mute:                      make(map[string]bool),
+		SubGains:                  make(map[string]int),
+		OutputFixed:               parseBool(i.OutputFixed.Value),
+		HeadphoneConnected:        parseBool(i.HeadphoneConnected.Value),
+		Loudness:                  parseBool(i.Loudness.Value),
+		SpeakerSize:               parseInt(i.SpeakerSize.Value),
+		SubCrossover:              parseInt(i.SubCrossover.Value),
+		SubPolarity:               parseInt(i.SubPolarity.Value),
+		SubEnabled:                parseBool(i.SubEnabled.Value),
+		SonarEnabled:              parseBool(i.SonarEnabled.Value),
+		SonarCalibrationAvailable: parseBool(i.SonarCalibrationAvailable.Value),
+		PresetNameList:            parseList(i.PresetNameList.Value),
+		DialogLevel:               parseBool(i.DialogLevel.Value),
+		SpeechEnhanceEnabled:      parseBool(i.SpeechEnhanceEnabled.Value),
+		NightMode:                 parseBool(i.NightMode.Value),
+		SurroundEnabled:           parseBool(i.SurroundEnabled.Value),
+		SurroundMode:              parseInt(i.SurroundMode.Value),
+		SurroundLevel:             parseInt(i.SurroundLevel.Value),
+		MusicSurroundLevel:        parseInt(i.MusicSurroundLevel.Value),
+		AudioDelay:                parseInt(i.AudioDelay.Value),
+		AudioDelayLeftRear:        parseInt(i.AudioDelayLeftRear.Value),
+		AudioDelayRightRear:       parseInt(i.AudioDelayRightRear.Value),
+		HeightChannelLevel:        parseInt(i.HeightChannelLevel.Value),
+		Unknown:                   i.Unknown,
+		present:                   i.present,
+	}
+	for _, v := range i.Volume {
+		c.Volume[v.Channel] = parseInt(v.Value)
+	}
+	for _, v := range i.VolumeDB {
+		c.VolumeDB[v.Channel] = parseInt(v.Value)
+	}
+	for _, m := range i.Mute {
+		c.Mute[m.Channel] = parseBool(m.Value)
+	}
+	for _, v := range i.Bass {
+		if isMaster(v.Channel) {
+			c.Bass = parseInt(v.Value)
+		}
+	}
+	for _, v := range i.Treble {
+		if isMaster(v.Channel) {
+			c.Treble = parseInt(v.Value)
+		}
+	}
+	for _, v := range i.SubGain {
+		channel := v.Channel
+		if channel == "" {
+			channel = "Master"
+		}
+		c.SubGains[channel] = parseInt(v.Value)
+		if isMaster(v.Channel) {
+			c.SubGain = c.SubGains[channel]
+		}
+	}
+	return c
+}
+
+// isMaster reports whether the channel of a value refers to the whole player.
+func isMaster(channel string) bool {
+	return channel == "" || channel == "Master"
+}
+
+func parseInt(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func parseBool(s string) bool {
+	return s == "1" || strings.EqualFold(s, "true")
+}
+
+// parseList splits comma separated values such as "Play, Stop, Pause".
+func parseList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// parseDuration parses durations such as "0:03:25" or "0:03:25.000", values the device
+// reports as NOT_IMPLEMENTED are zero.
+func parseDuration(s string) time.Duration {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second))
+}
+
+// parseItem returns the first item of the DIDL-Lite document or nil.
+func parseItem(raw string) *didl.Item {
+	if raw == "" || raw == "NOT_IMPLEMENTED" {
+		return nil
+	}
+	metadata, err := ParseDIDL(raw)
+	if err != nil || len(metadata.Item) == 0 {
+		return nil
+	}
+	return &metadata.Item[0]
+}
+
+// lastChangeTypes maps the services emitting LastChange events to their state variable types.
+var lastChangeTypes = map[string]map[string]string{
+	"AVTransport":           avt.StateVariableTypes,
+	"GroupRenderingControl": rcg.StateVariableTypes,
+	"Queue":                 que.StateVariableTypes,
+	"RenderingControl":      ren.StateVariableTypes,
+	"VirtualLineIn":         vli.StateVariableTypes,
+}
+
+// LastChangeEvent is a LastChange event decoded based on the state variables the SCPD of the
+// service defines, as opposed to the per service types such as AVTransportLastChange.
+type LastChangeEvent struct {
+	// Name of the service package, e.g. AVTransport
+	Service   string
+	Instances []LastChangeInstance
+}
+
+// LastChangeInstance holds the values of an InstanceID or a QueueID of the event.
+type LastChangeInstance struct {
+	// Name of the element, e.g. InstanceID or QueueID
+	Kind   string
+	ID     string
+	Values []LastChangeValue
+}
+
+// LastChangeValue is a state variable carried by a LastChange event.
+type LastChangeValue struct {
+	Name string
+	// Empty unless the state variable is set per channel, e.g. Master, LF or RF
+	Channel string
+	// bool, int64, float64 or string depending on the SCPD data type
+	Value interface{}
+	// Value as sent by the device
+	Raw string
+}
+
+// Instance returns the instance with the given id.
+func (e *LastChangeEvent) Instance(id string) (*LastChangeInstance, bool) {
+	for i := range e.Instances {
+		if e.Instances[i].ID == id {
+			return &e.Instances[i], true
+		}
+	}
+	return nil, false
+}
+
+// Get returns the value of the state variable, the Master value if it is set per channel.
+func (i *LastChangeInstance) Get(name string) (LastChangeValue, bool) {
+	for _, v := range i.Values {
+		if v.Name == name && isMaster(v.Channel) {
+			return v, true
+		}
+	}
+	return LastChangeValue{}, false
+}
+
+// Channel returns the value of the state variable for the given channel.
+func (i *LastChangeInstance) Channel(name, channel string) (LastChangeValue, bool) {
+	for _, v := range i.Values {
+		if v.Name == name && v.Channel == channel {
+			return v, true
+		}
+	}
+	return LastChangeValue{}, false
+}
+
+// DecodeLastChange decodes the LastChange document of the service, e.g. AVTransport.
+// Values of state variables the SCPD of the service does not define are kept as strings.
+func DecodeLastChange(service string, data []byte) (LastChangeEvent, error) {
+	evt := LastChangeEvent{Service: service}
+	types := lastChangeTypes[service]
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return evt, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch depth {
+			case 1:
+				if t.Name.Local != "Event" {
+					return evt, fmt.Errorf("unexpected root element %s", t.Name.Local)
+				}
+			case 2:
+				evt.Instances = append(evt.Instances, LastChangeInstance{Kind: t.Name.Local, ID: attr(t, "val")})
+			case 3:
+				instance := &evt.Instances[len(evt.Instances)-1]
+				raw := attr(t, "val")
+				instance.Values = append(instance.Values, LastChangeValue{
+					Name:    t.Name.Local,
+					Channel: attr(t, "channel"),
+					Value:   typedValue(types[t.Name.Local], raw),
+					Raw:     raw,
+				})
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return evt, nil
+}
+
+// isLastChange reports whether evt is the LastChange state variable of a service.
+func isLastChange(evt interface{}) bool {
+	return reflect.TypeOf(evt).Name() == "LastChange"
+}
+
+// lastChangeEvent delivers the LastChange state variable of a service as LastChangeEvent.
+func (zp *ZonePlayer) lastChangeEvent(evt interface{}, fn EventHandlerFunc) {
+	service := path.Base(reflect.TypeOf(evt).PkgPath())
+	levt, err := DecodeLastChange(service, []byte(fmt.Sprint(evt)))
+	if err != nil {
+		zp.logger.Warn("failed to unmarshal event", "event", fmt.Sprintf("%T", evt), "error", err)
+		fn(newRawEvent(evt))
+		return
+	}
+	fn(levt)
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// typedValue converts raw based on the SCPD data type, values which fail to convert stay strings.
+func typedValue(dataType, raw string) interface{} {
+	switch dataType {
+	case "boolean":
+		switch raw {
+		case "0", "false", "no":
+			return false
+		case "1", "true", "yes":
+			return true
+		}
+	case "ui1", "ui2", "ui4", "ui8", "i1", "i2", "i4", "i8", "int":
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return n
+		}
+	case "r4", "r8", "number", "float", "fixed.14.4":
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			return f
+		}
+	}
+	return raw
+}
@@ -0,0 +1,188 @@
+package sonos
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+func TestAVTransportLastChangeTyped(t *testing.T) {
+	raw := `<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/" xmlns:r="urn:schemas-rinconnetworks-com:metadata-1-0/"><InstanceID val="0"><TransportState val="PLAYING"/><CurrentPlayMode val="SHUFFLE_NOREPEAT"/><CurrentCrossfadeMode val="1"/><NumberOfTracks val="12"/><CurrentTrack val="3"/><CurrentTrackDuration val="0:03:25"/><CurrentTrackMetaData val="&lt;DIDL-Lite xmlns:dc=&quot;http://purl.org/dc/elements/1.1/&quot; xmlns:upnp=&quot;urn:schemas-upnp-org:metadata-1-0/upnp/&quot; xmlns=&quot;urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/&quot;&gt;&lt;item id=&quot;-1&quot; parentID=&quot;-1&quot;&gt;&lt;dc:title&gt;Song&lt;/dc:title&gt;&lt;dc:creator&gt;Artist&lt;/dc:creator&gt;&lt;/item&gt;&lt;/DIDL-Lite&gt;"/><CurrentTransportActions val="Set, Play, Stop, Pause, Seek"/><CurrentMediaDuration val="NOT_IMPLEMENTED"/></InstanceID></Event>`
+
+	var e AVTransportLastChange
+	if err := xml.Unmarshal([]byte(raw), &e); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	c := e.Typed()
+
+	if c.TransportState != avt.TransportState_PLAYING || c.CurrentPlayMode != avt.CurrentPlayMode_SHUFFLE_NOREPEAT || !c.CurrentCrossfadeMode {
+		t.Errorf("Unexpected transport: %+v", c)
+	}
+	if c.NumberOfTracks != 12 || c.CurrentTrack != 3 || c.CurrentTrackDuration != 3*time.Minute+25*time.Second || c.CurrentMediaDuration != 0 {
+		t.Errorf("Unexpected track: %+v", c)
+	}
+	if c.CurrentTrackMetaData == nil || c.CurrentTrackMetaData.Title[0].Value != "Song" || c.CurrentTrackMetaData.Creator[0].Value != "Artist" {
+		t.Errorf("Unexpected metadata: %+v", c.CurrentTrackMetaData)
+	}
+	if len(c.CurrentTransportActions) != 5 || c.CurrentTransportActions[4] != "Seek" {
+		t.Errorf("Unexpected actions: %v", c.CurrentTransportActions)
+	}
+	if !c.Has("TransportState") || !c.Has("CurrentTrackMetaData") || c.Has("NextTrackURI") {
+		t.Errorf("Unexpected presence: %v", c.present)
+	}
+}
+
+func TestRenderingControlLastChangeTyped(t *testing.T) {
+	raw := `<Event xmlns="urn:schemas-upnp-org:metadata-1-0/RCS/"><InstanceID val="0"><Volume channel="Master" val="25"/><Volume channel="LF" val="100"/><Mute channel="Master" val="1"/><Bass val="-3"/></InstanceID></Event>`
+
+	var e RenderingControlLastChange
+	if err := xml.Unmarshal([]byte(raw), &e); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	c := e.Typed()
+
+	if c.Volume["Master"] != 25 || c.Volume["LF"] != 100 || !c.Mute["Master"] || c.Bass != -3 {
+		t.Errorf("Unexpected change: %+v", c)
+	}
+	if !c.Has("Volume") || !c.Has("Bass") || c.Has("Treble") {
+		t.Errorf("Unexpected presence: %v", c.present)
+	}
+
+	// Events built in code carry only what they set as present
+	empty := RenderingControlLastChange{}
+	if typed := empty.Typed(); typed.Has("Treble") {
+		t.Error("Expected an empty event to carry no field")
+	}
+}
+
+func TestRenderingControlLastChangeFixtures(t *testing.T) {
+	fixtures, err := filepath.Glob("testdata/rendering_control_*.xml")
+	if err != nil || len(fixtures) == 0 {
+		t.Fatalf("No fixtures found: %v", err)
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			raw, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatalf("ReadFile failed: %v", err)
+			}
+			var e RenderingControlLastChange
+			if err := xml.Unmarshal(raw, &e); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if len(e.InstanceID.Unknown) != 0 {
+				t.Errorf("Expected every element to be known, got %v", e.InstanceID.Unknown)
+			}
+		})
+	}
+
+	raw, err := os.ReadFile("testdata/rendering_control_arc.xml")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	var e RenderingControlLastChange
+	if err := xml.Unmarshal(raw, &e); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	c := e.Typed()
+	if c.Bass != 2 || c.Treble != -1 || !c.Loudness || c.SubGain != 3 || c.VolumeDB["Master"] != -4864 {
+		t.Errorf("Unexpected values: %+v", c)
+	}
+	if !c.DialogLevel || !c.NightMode || !c.SurroundEnabled || c.SurroundLevel != 2 || c.MusicSurroundLevel != -3 || c.AudioDelayLeftRear != 2 || c.HeightChannelLevel != 1 {
+		t.Errorf("Unexpected home theater values: %+v", c)
+	}
+
+	raw, err = os.ReadFile("testdata/rendering_control_amp.xml")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	e = RenderingControlLastChange{}
+	if err := xml.Unmarshal(raw, &e); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	c = e.Typed()
+	if c.SubGain != 2 || len(c.SubGains) != 3 || c.SubGains["Master"] != 2 || c.SubGains["LF"] != -1 || c.SubGains["RF"] != 3 {
+		t.Errorf("Unexpected sub gains: %d %v", c.SubGain, c.SubGains)
+	}
+}
+
+func TestRenderingControlLastChangeUnknown(t *testing.T) {
+	raw := `<Event xmlns="urn:schemas-upnp-org:metadata-1-0/RCS/"><InstanceID val="0"><Volume channel="Master" val="10"/><FutureLevel val="4"/><FutureGain channel="LF" val="-2"/></InstanceID></Event>`
+
+	var e RenderingControlLastChange
+	if err := xml.Unmarshal([]byte(raw), &e); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	unknown := e.InstanceID.Unknown
+	if len(unknown) != 2 || unknown["FutureLevel"] != "4" || unknown["FutureGain/LF"] != "-2" {
+		t.Errorf("Unexpected unknown elements: %v", unknown)
+	}
+	if c := e.Typed(); c.Volume["Master"] != 10 || !c.Has("FutureLevel") {
+		t.Errorf("Unexpected change: %+v", c)
+	}
+}
+
+func TestDecodeLastChange(t *testing.T) {
+	raw := `<Event xmlns="urn:schemas-upnp-org:metadata-1-0/RCS/"><InstanceID val="0"><Volume channel="Master" val="25"/><Volume channel="LF" val="100"/><Mute channel="Master" val="1"/><Bass val="-3"/><SubCrossover val="80"/><Unheard val="x"/></InstanceID><InstanceID val="1"><Volume channel="Master" val="7"/></InstanceID></Event>`
+
+	e, err := DecodeLastChange("RenderingControl", []byte(raw))
+	if err != nil {
+		t.Fatalf("DecodeLastChange failed: %v", err)
+	}
+	if len(e.Instances) != 2 || e.Instances[0].Kind != "InstanceID" {
+		t.Fatalf("Expected two instances, got %+v", e.Instances)
+	}
+
+	i, _ := e.Instance("0")
+	tests := []struct {
+		name    string
+		channel string
+		value   interface{}
+	}{
+		{"Volume", "Master", int64(25)},
+		{"Volume", "LF", int64(100)},
+		{"Mute", "Master", true},
+		{"Bass", "", int64(-3)},
+		// A string in the SCPD
+		{"SubCrossover", "", "80"},
+		{"Unheard", "", "x"},
+	}
+	for _, tt := range tests {
+		if v, ok := i.Channel(tt.name, tt.channel); !ok || v.Value != tt.value {
+			t.Errorf("Expected %s/%s to be %#v, got %#v", tt.name, tt.channel, tt.value, v.Value)
+		}
+	}
+
+	i, ok := e.Instance("1")
+	if v, _ := i.Get("Volume"); !ok || v.Value != int64(7) {
+		t.Errorf("Unexpected second instance: %+v", i)
+	}
+}
+
+func TestDecodeLastChangeQueue(t *testing.T) {
+	raw := `<Event xmlns="urn:schemas-sonos-com:metadata-1-0/Queue/"><QueueID val="0"><UpdateID val="5"/></QueueID><QueueID val="1"><UpdateID val="2"/><Curated val="1"/></QueueID></Event>`
+
+	e, err := DecodeLastChange("Queue", []byte(raw))
+	if err != nil {
+		t.Fatalf("DecodeLastChange failed: %v", err)
+	}
+	i, ok := e.Instance("1")
+	if !ok || i.Kind != "QueueID" {
+		t.Fatalf("Expected the second queue, got %+v", e.Instances)
+	}
+	if v, _ := i.Get("UpdateID"); v.Value != int64(2) {
+		t.Errorf("Unexpected UpdateID %#v", v.Value)
+	}
+	if v, _ := i.Get("Curated"); v.Value != true {
+		t.Errorf("Unexpected Curated %#v", v.Value)
+	}
+
+	if _, err := DecodeLastChange("Queue", []byte(`<Propertyset/>`)); err == nil {
+		t.Error("Expected an error for a document other than Event")
+	}
+}
@@ -58,6 +58,26 @@ type SetGroupVolumeParams struct {
 	Volume   int    `json:"volume"`
 }
 
+type SetShuffleParams struct {
+	RoomName string `json:"room_name"`
+	Shuffle  bool   `json:"shuffle"`
+}
+
+type SetRepeatParams struct {
+	RoomName string `json:"room_name"`
+	Repeat   string `json:"repeat"`
+}
+
+type SetCrossfadeParams struct {
+	RoomName  string `json:"room_name"`
+	Crossfade bool   `json:"crossfade"`
+}
+
+type SetSleepTimerParams struct {
+	RoomName string `json:"room_name"`
+	Minutes  int    `json:"minutes"`
+}
+
 type SearchSpotifyParams struct {
 	Query      string `json:"query"`
 	SearchType string `json:"search_type"`
@@ -2,30 +2,21 @@ package handlers
 
 import (
 	"context"
-	"encoding/xml"
 	"fmt"
+	"time"
 
 	"github.com/caglar10ur/sonos"
-	"github.com/caglar10ur/sonos/didl"
-	avt "github.com/caglar10ur/sonos/services/AVTransport"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 func (h *Handlers) GetNowPlayingHandler(ctx context.Context, req *mcp.CallToolRequest, params RoomNameParams) (*mcp.CallToolResult, any, error) {
 	return h.withRoom(ctx, params.RoomName, func(zp *sonos.ZonePlayer) (*mcp.CallToolResult, any, error) {
-		info, err := zp.AVTransport.GetPositionInfo(&avt.GetPositionInfoArgs{
-			InstanceID: 0,
-		})
+		track, err := zp.NowPlaying(ctx)
 		if err != nil {
 			return handleError(err, params.RoomName), nil, nil
 		}
 
-		var lite didl.Lite
-		if err := xml.Unmarshal([]byte(info.TrackMetaData), &lite); err != nil {
-			return handleError(err, params.RoomName), nil, nil
-		}
-
-		if len(lite.Item) == 0 {
+		if track.Source == sonos.SourceNone || track.Title == "" && track.Station == "" {
 			return &mcp.CallToolResult{
 				Content: []mcp.Content{
 					&mcp.TextContent{Text: "Nothing is playing"},
@@ -33,21 +24,17 @@ func (h *Handlers) GetNowPlayingHandler(ctx context.Context, req *mcp.CallToolRe
 			}, nil, nil
 		}
 
-		item := lite.Item[0]
-		var title, artist, album string
-		if len(item.Title) > 0 {
-			title = item.Title[0].Value
-		}
-		if len(item.Creator) > 0 {
-			artist = item.Creator[0].Value
+		text := fmt.Sprintf("Title: %s, Artist: %s, Album: %s", track.Title, track.Artist, track.Album)
+		if track.Station != "" {
+			text = fmt.Sprintf("Station: %s, %s", track.Station, text)
 		}
-		if len(item.Album) > 0 {
-			album = item.Album[0].Value
+		if track.Duration > 0 {
+			text += fmt.Sprintf(", Position: %s/%s", track.Position, track.Duration)
 		}
 
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
-				&mcp.TextContent{Text: fmt.Sprintf("Title: %s, Artist: %s, Album: %s", title, artist, album)},
+				&mcp.TextContent{Text: text},
 			},
 		}, nil, nil
 	})
@@ -142,3 +129,123 @@ func (h *Handlers) GetPositionInfoHandler(ctx context.Context, req *mcp.CallTool
 		}, nil, nil
 	})
 }
+
+func (h *Handlers) GetPlayModeHandler(ctx context.Context, req *mcp.CallToolRequest, params RoomNameParams) (*mcp.CallToolResult, any, error) {
+	return h.withRoom(ctx, params.RoomName, func(zp *sonos.ZonePlayer) (*mcp.CallToolResult, any, error) {
+		mode, err := zp.GetPlayMode()
+		if err != nil {
+			return handleError(err, params.RoomName), nil, nil
+		}
+
+		crossfade, err := zp.GetCrossfade()
+		if err != nil {
+			return handleError(err, params.RoomName), nil, nil
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: fmt.Sprintf("Play mode in %s: shuffle %t, repeat %s, crossfade %t", params.RoomName, mode.Shuffle, mode.Repeat, crossfade)},
+			},
+		}, nil, nil
+	})
+}
+
+func (h *Handlers) SetShuffleHandler(ctx context.Context, req *mcp.CallToolRequest, params SetShuffleParams) (*mcp.CallToolResult, any, error) {
+	return h.withRoom(ctx, params.RoomName, func(zp *sonos.ZonePlayer) (*mcp.CallToolResult, any, error) {
+		err := zp.SetShuffle(params.Shuffle)
+		if err != nil {
+			return handleError(err, params.RoomName), nil, nil
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: fmt.Sprintf("Shuffle in %s set to %t", params.RoomName, params.Shuffle)},
+			},
+		}, nil, nil
+	})
+}
+
+func (h *Handlers) SetRepeatHandler(ctx context.Context, req *mcp.CallToolRequest, params SetRepeatParams) (*mcp.CallToolResult, any, error) {
+	return h.withRoom(ctx, params.RoomName, func(zp *sonos.ZonePlayer) (*mcp.CallToolResult, any, error) {
+		repeat, err := sonos.ParseRepeatMode(params.Repeat)
+		if err != nil {
+			return handleError(err, params.RoomName), nil, nil
+		}
+
+		err = zp.SetRepeat(repeat)
+		if err != nil {
+			return handleError(err, params.RoomName), nil, nil
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: fmt.Sprintf("Repeat in %s set to %s", params.RoomName, repeat)},
+			},
+		}, nil, nil
+	})
+}
+
+func (h *Handlers) SetCrossfadeHandler(ctx context.Context, req *mcp.CallToolRequest, params SetCrossfadeParams) (*mcp.CallToolResult, any, error) {
+	return h.withRoom(ctx, params.RoomName, func(zp *sonos.ZonePlayer) (*mcp.CallToolResult, any, error) {
+		err := zp.SetCrossfade(params.Crossfade)
+		if err != nil {
+			return handleError(err, params.RoomName), nil, nil
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: fmt.Sprintf("Crossfade in %s set to %t", params.RoomName, params.Crossfade)},
+			},
+		}, nil, nil
+	})
+}
+
+func (h *Handlers) SetSleepTimerHandler(ctx context.Context, req *mcp.CallToolRequest, params SetSleepTimerParams) (*mcp.CallToolResult, any, error) {
+	return h.withRoom(ctx, params.RoomName, func(zp *sonos.ZonePlayer) (*mcp.CallToolResult, any, error) {
+		err := zp.SetSleepTimer(time.Duration(params.Minutes) * time.Minute)
+		if err != nil {
+			return handleError(err, params.RoomName), nil, nil
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: fmt.Sprintf("Playback in %s will stop in %d minutes", params.RoomName, params.Minutes)},
+			},
+		}, nil, nil
+	})
+}
+
+func (h *Handlers) CancelSleepTimerHandler(ctx context.Context, req *mcp.CallToolRequest, params RoomNameParams) (*mcp.CallToolResult, any, error) {
+	return h.withRoom(ctx, params.RoomName, func(zp *sonos.ZonePlayer) (*mcp.CallToolResult, any, error) {
+		err := zp.CancelSleepTimer()
+		if err != nil {
+			return handleError(err, params.RoomName), nil, nil
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: fmt.Sprintf("Sleep timer in %s cancelled", params.RoomName)},
+			},
+		}, nil, nil
+	})
+}
+
+func (h *Handlers) GetSleepTimerHandler(ctx context.Context, req *mcp.CallToolRequest, params RoomNameParams) (*mcp.CallToolResult, any, error) {
+	return h.withRoom(ctx, params.RoomName, func(zp *sonos.ZonePlayer) (*mcp.CallToolResult, any, error) {
+		remaining, err := zp.SleepTimerRemaining()
+		if err != nil {
+			return handleError(err, params.RoomName), nil, nil
+		}
+
+		text := fmt.Sprintf("No sleep timer set in %s", params.RoomName)
+		if remaining > 0 {
+			text = fmt.Sprintf("Playback in %s will stop in %s", params.RoomName, remaining)
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: text},
+			},
+		}, nil, nil
+	})
+}
@@ -123,6 +123,9 @@ func registerTools(s *mcp.Server, h *handlers.Handlers, spotifyClient *spotify.S
 		{"get_zone_group_attributes", "Get the zone group attributes for a given room", h.GetZoneGroupAttributesHandler},
 		{"get_group_volume", "Get the current volume of a Sonos group", h.GetGroupVolumeHandler},
 		{"get_media_info", "Get the current media information on a Sonos device", h.GetMediaInfoHandler},
+		{"get_play_mode", "Get the shuffle, repeat and crossfade settings of a Sonos device", h.GetPlayModeHandler},
+		{"get_sleep_timer", "Get how long the sleep timer of a Sonos device has left", h.GetSleepTimerHandler},
+		{"cancel_sleep_timer", "Cancel the sleep timer of a Sonos device", h.CancelSleepTimerHandler},
 	}
 
 	for _, t := range roomTools {
@@ -160,6 +163,26 @@ func registerTools(s *mcp.Server, h *handlers.Handlers, spotifyClient *spotify.S
 		Description: "Set the volume of a Sonos group",
 	}, h.SetGroupVolumeHandler)
 
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "set_shuffle",
+		Description: "Turn shuffle on or off on a Sonos device, keeping the repeat mode",
+	}, h.SetShuffleHandler)
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "set_repeat",
+		Description: "Set the repeat mode (off, all or one) of a Sonos device, keeping shuffle",
+	}, h.SetRepeatHandler)
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "set_crossfade",
+		Description: "Turn crossfade on or off on a Sonos device",
+	}, h.SetCrossfadeHandler)
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "set_sleep_timer",
+		Description: "Stop playback on a Sonos device after the given number of minutes",
+	}, h.SetSleepTimerHandler)
+
 	if spotifyClient != nil {
 		mcp.AddTool(s, &mcp.Tool{
 			Name:        "search_spotify",
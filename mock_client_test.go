@@ -12,6 +12,8 @@ import (
 // MockRoundTripper implements http.RoundTripper to intercept requests
 type MockRoundTripper struct {
 	Handlers map[string]func(*http.Request) (*http.Response, error)
+	// Device descriptions keyed by host, mockDeviceDescription is used for unknown hosts
+	Descriptions map[string]string
 }
 
 func (m *MockRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -26,9 +28,13 @@ func (m *MockRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 
 	// 2. Check for Device Description request (used in NewZonePlayer)
 	if strings.Contains(req.URL.Path, "device_description.xml") {
+		description, ok := m.Descriptions[req.URL.Host]
+		if !ok {
+			description = mockDeviceDescription
+		}
 		return &http.Response{
 			StatusCode: http.StatusOK,
-			Body:       io.NopCloser(bytes.NewBufferString(mockDeviceDescription)),
+			Body:       io.NopCloser(bytes.NewBufferString(description)),
 			Header:     make(http.Header),
 		}, nil
 	}
@@ -56,6 +62,17 @@ func NewMockZonePlayer(t *testing.T, handlers map[string]func(*http.Request) (*h
 	return zp
 }
 
+// withModel replaces the Sonos One of the device description with another model.
+func withModel(description, name, number string) string {
+	return strings.NewReplacer("Sonos One", name, "<modelNumber>S13<", "<modelNumber>"+number+"<").Replace(description)
+}
+
+// Helper to create a device description for another room
+func mockDeviceDescriptionFor(room, uuid string) string {
+	description := strings.ReplaceAll(mockDeviceDescription, "Kitchen", room)
+	return strings.ReplaceAll(description, "RINCON_000E58CDCA4001400", uuid)
+}
+
 const mockDeviceDescription = `<?xml version="1.0" encoding="utf-8" ?>
 <root xmlns="urn:schemas-upnp-org:device-1-0">
     <specVersion>
@@ -67,6 +84,7 @@ const mockDeviceDescription = `<?xml version="1.0" encoding="utf-8" ?>
         <friendlyName>Kitchen</friendlyName>
         <manufacturer>Sonos, Inc.</manufacturer>
         <modelName>Sonos One</modelName>
+        <modelNumber>S13</modelNumber>
         <modelDescription>Sonos One Audio Player</modelDescription>
         <UDN>uuid:RINCON_000E58CDCA4001400</UDN>
         <serialNum>00-11-22-33-44-55:0</serialNum>
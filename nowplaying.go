@@ -0,0 +1,241 @@
+package sonos
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/caglar10ur/sonos/didl"
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+// Source is the kind of source the transport plays from.
+type Source string
+
+const (
+	SourceNone   Source = ""
+	SourceQueue  Source = "queue"
+	SourceRadio  Source = "radio"
+	SourceLineIn Source = "line-in"
+	SourceTV     Source = "tv"
+	// SourceStream is any other URI, e.g. a file or a stream set by a music service
+	SourceStream Source = "stream"
+)
+
+// Track is what a player is currently playing.
+type Track struct {
+	Title       string
+	Artist      string
+	Album       string
+	AlbumArtist string
+	// Absolute URL of the album art
+	AlbumArtURL string
+	// Zero for radio, line-in and TV
+	Duration time.Duration
+	Position time.Duration
+	// Number of the track in the queue, starting from 1
+	Number int
+	URI    string
+
+	Source Source
+	// Name of the music service, e.g. Spotify, if known
+	Service string
+	// Name of the radio station
+	Station string
+	// What the radio station currently streams, e.g. "Artist - Title"
+	StreamContent string
+
+	State avt.TransportStateEnum
+	// UUID of the coordinator the player is grouped with, empty for coordinators
+	Coordinator string
+}
+
+// Names of the music services by their service IDs, the sid parameter of the URIs.
+var musicServices = map[string]string{
+	"2":   "Deezer",
+	"9":   "Spotify",
+	"160": "SoundCloud",
+	"201": "Amazon Music",
+	"204": "Apple Music",
+	"254": "TuneIn",
+	"284": "YouTube Music",
+}
+
+// NowPlaying returns what the player is currently playing. Members of a group report what
+// their coordinator plays.
+func (z *ZonePlayer) NowPlaying(ctx context.Context) (*Track, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	media, err := z.AVTransport.GetMediaInfo(&avt.GetMediaInfoArgs{InstanceID: 0})
+	if err != nil {
+		return nil, err
+	}
+
+	if uuid, ok := strings.CutPrefix(media.CurrentURI, "x-rincon:"); ok {
+		coordinator, err := z.coordinator(uuid)
+		if err != nil {
+			return nil, err
+		}
+		t, err := coordinator.NowPlaying(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("coordinator %s: %w", uuid, err)
+		}
+		t.Coordinator = uuid
+		return t, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	transport, err := z.AVTransport.GetTransportInfo(&avt.GetTransportInfoArgs{InstanceID: 0})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	position, err := z.GetPositionInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Track{
+		URI:      position.TrackURI,
+		Number:   int(position.Track),
+		Duration: parseDuration(position.TrackDuration),
+		Position: parseDuration(position.RelTime),
+		Source:   source(media.CurrentURI),
+		Service:  musicService(media.CurrentURI),
+		State:    transport.CurrentTransportState,
+	}
+	if t.Service == "" {
+		t.Service = musicService(position.TrackURI)
+	}
+	if t.Source != SourceQueue {
+		// Only tracks of the queue have a number
+		t.Number = 0
+	}
+
+	if item := parseItem(position.TrackMetaData); item != nil {
+		t.Title = didl.First(item.Title)
+		t.Artist = didl.First(item.Creator)
+		t.Album = didl.First(item.Album)
+		t.AlbumArtist = didl.First(item.AlbumArtist)
+		t.AlbumArtURL = z.absoluteURL(didl.First(item.AlbumArtURI))
+		t.StreamContent = didl.First(item.StreamContent)
+	}
+
+	// The media carries the name of the station or the input
+	if item := parseItem(media.CurrentURIMetaData); item != nil {
+		switch t.Source {
+		case SourceRadio:
+			t.Station = didl.First(item.Title)
+			if t.AlbumArtURL == "" {
+				t.AlbumArtURL = z.absoluteURL(didl.First(item.AlbumArtURI))
+			}
+		case SourceLineIn, SourceTV:
+			if t.Title == "" {
+				t.Title = didl.First(item.Title)
+			}
+		}
+	}
+	if t.Source == SourceRadio {
+		t.radio()
+	}
+	return t, nil
+}
+
+// radio takes the title and artist from the stream content, the title of the track being
+// the stream itself.
+func (t *Track) radio() {
+	if t.Title == t.Station || strings.Contains(t.Title, "://") || strings.HasPrefix(t.Title, "x-sonosapi") {
+		t.Title = ""
+	}
+	if t.StreamContent == "" || t.Title != "" && t.Artist != "" {
+		return
+	}
+
+	// e.g. TYPE=SNG|TITLE Title|ARTIST Artist|ALBUM Album
+	if strings.Contains(t.StreamContent, "|") {
+		for _, field := range strings.Split(t.StreamContent, "|") {
+			key, value, _ := strings.Cut(field, " ")
+			switch key {
+			case "TITLE":
+				t.Title = value
+			case "ARTIST":
+				t.Artist = value
+			case "ALBUM":
+				t.Album = value
+			}
+		}
+		return
+	}
+	if artist, title, ok := strings.Cut(t.StreamContent, " - "); ok {
+		t.Artist, t.Title = artist, title
+	}
+}
+
+// coordinator returns the player with the UUID.
+func (z *ZonePlayer) coordinator(uuid string) (*ZonePlayer, error) {
+	zoneGroupState, err := z.GetZoneGroupState()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range zoneGroupState.ZoneGroups {
+		for _, member := range group.ZoneGroupMember {
+			if member.UUID == uuid {
+				return z.zonePlayerAt(member.UUID, member.Location)
+			}
+		}
+	}
+	return nil, fmt.Errorf("coordinator %s not found", uuid)
+}
+
+// absoluteURL resolves the album art URI, which is often relative to the player.
+func (z *ZonePlayer) absoluteURL(ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return z.Location().ResolveReference(u).String()
+}
+
+// source returns the kind of source of the media URI.
+func source(uri string) Source {
+	scheme, _, _ := strings.Cut(uri, ":")
+	switch scheme {
+	case "":
+		return SourceNone
+	case "x-rincon-queue":
+		return SourceQueue
+	case "x-rincon-stream":
+		return SourceLineIn
+	case "x-sonos-htastream":
+		return SourceTV
+	case "x-sonosapi-stream", "x-sonosapi-radio", "x-rincon-mp3radio", "aac", "hls-radio":
+		return SourceRadio
+	}
+	return SourceStream
+}
+
+// musicService returns the name of the music service of the URI, if known.
+func musicService(uri string) string {
+	if strings.HasPrefix(uri, "x-sonos-spotify:") {
+		return "Spotify"
+	}
+	_, query, ok := strings.Cut(uri, "?")
+	if !ok {
+		return ""
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return ""
+	}
+	return musicServices[values.Get("sid")]
+}
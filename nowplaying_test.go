@@ -0,0 +1,145 @@
+package sonos
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"testing"
+	"time"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+// nowPlayingInfo is what a mocked player reports to NowPlaying.
+type nowPlayingInfo struct {
+	uri, uriMetaData        string
+	state                   string
+	track                   string
+	trackURI, trackMetaData string
+	trackDuration, relTime  string
+}
+
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// nowPlayingHandlers mocks the players by their host.
+func nowPlayingHandlers(players map[string]nowPlayingInfo) map[string]func(*http.Request) (*http.Response, error) {
+	return map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:AVTransport:1#GetMediaInfo": func(req *http.Request) (*http.Response, error) {
+			p := players[req.URL.Host]
+			return mockResponseHandler("AVTransport", "GetMediaInfo", "<NrTracks>1</NrTracks><CurrentURI>"+escapeXML(p.uri)+"</CurrentURI><CurrentURIMetaData>"+escapeXML(p.uriMetaData)+"</CurrentURIMetaData>")(req)
+		},
+		"urn:schemas-upnp-org:service:AVTransport:1#GetTransportInfo": func(req *http.Request) (*http.Response, error) {
+			p := players[req.URL.Host]
+			return mockResponseHandler("AVTransport", "GetTransportInfo", "<CurrentTransportState>"+p.state+"</CurrentTransportState>")(req)
+		},
+		"urn:schemas-upnp-org:service:AVTransport:1#GetPositionInfo": func(req *http.Request) (*http.Response, error) {
+			p := players[req.URL.Host]
+			return mockResponseHandler("AVTransport", "GetPositionInfo", "<Track>"+p.track+"</Track><TrackDuration>"+p.trackDuration+"</TrackDuration><TrackMetaData>"+escapeXML(p.trackMetaData)+"</TrackMetaData><TrackURI>"+escapeXML(p.trackURI)+"</TrackURI><RelTime>"+p.relTime+"</RelTime>")(req)
+		},
+		"urn:schemas-upnp-org:service:ZoneGroupTopology:1#GetZoneGroupState": mockResponseHandler("ZoneGroupTopology", "GetZoneGroupState", "<ZoneGroupState>"+escapeXML(`<ZoneGroupState><ZoneGroups><ZoneGroup Coordinator="RINCON_BBB" ID="RINCON_BBB:2"><ZoneGroupMember UUID="RINCON_000E58CDCA4001400" Location="http://192.168.1.100:1400/xml/device_description.xml" ZoneName="Kitchen" /><ZoneGroupMember UUID="RINCON_BBB" Location="http://192.168.1.101:1400/xml/device_description.xml" ZoneName="Office" /></ZoneGroup></ZoneGroups></ZoneGroupState>`)+"</ZoneGroupState>"),
+	}
+}
+
+func didlItem(inner string) string {
+	return `<DIDL-Lite xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/" xmlns:r="urn:schemas-rinconnetworks-com:metadata-1-0/" xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/"><item id="-1" parentID="-1" restricted="true">` + inner + `</item></DIDL-Lite>`
+}
+
+func TestNowPlaying(t *testing.T) {
+	queue := nowPlayingInfo{
+		uri:           "x-rincon-queue:RINCON_000E58CDCA4001400#0",
+		state:         "PLAYING",
+		track:         "3",
+		trackURI:      "x-sonos-spotify:spotify%3atrack%3a123?sid=9&flags=8224&sn=1",
+		trackMetaData: didlItem(`<dc:title>Song</dc:title><dc:creator>Artist</dc:creator><upnp:album>Album</upnp:album><r:albumArtist>Album Artist</r:albumArtist><upnp:albumArtURI>/getaa?s=1&amp;u=x-sonos-spotify</upnp:albumArtURI>`),
+		trackDuration: "0:03:25",
+		relTime:       "0:01:10",
+	}
+	radio := nowPlayingInfo{
+		uri:           "x-sonosapi-stream:s1234?sid=254&flags=8224&sn=0",
+		uriMetaData:   didlItem(`<dc:title>Jazz FM</dc:title><upnp:class>object.item.audioItem.audioBroadcast</upnp:class>`),
+		state:         "PLAYING",
+		track:         "1",
+		trackURI:      "x-sonosapi-stream:s1234?sid=254&flags=8224&sn=0",
+		trackMetaData: didlItem(`<dc:title>x-sonosapi-stream:s1234?sid=254&amp;flags=8224&amp;sn=0</dc:title><r:streamContent>Miles Davis - So What</r:streamContent><upnp:albumArtURI>http://cdn.example.com/jazz.png</upnp:albumArtURI>`),
+		trackDuration: "0:00:00",
+		relTime:       "0:12:00",
+	}
+	lineIn := nowPlayingInfo{
+		uri:           "x-rincon-stream:RINCON_000E58CDCA4001400",
+		uriMetaData:   didlItem(`<dc:title>Turntable</dc:title>`),
+		state:         "PLAYING",
+		track:         "1",
+		trackURI:      "x-rincon-stream:RINCON_000E58CDCA4001400",
+		trackDuration: "NOT_IMPLEMENTED",
+		relTime:       "NOT_IMPLEMENTED",
+	}
+	tv := nowPlayingInfo{
+		uri:      "x-sonos-htastream:RINCON_000E58CDCA4001400:spdif",
+		state:    "PLAYING",
+		trackURI: "x-sonos-htastream:RINCON_000E58CDCA4001400:spdif",
+	}
+	member := nowPlayingInfo{
+		uri:   "x-rincon:RINCON_BBB",
+		state: "PLAYING",
+	}
+
+	tests := []struct {
+		name    string
+		players map[string]nowPlayingInfo
+		want    Track
+	}{
+		{"Queue", map[string]nowPlayingInfo{"192.168.1.100:1400": queue}, Track{
+			Title: "Song", Artist: "Artist", Album: "Album", AlbumArtist: "Album Artist",
+			AlbumArtURL: "http://192.168.1.100:1400/getaa?s=1&u=x-sonos-spotify",
+			Duration:    3*time.Minute + 25*time.Second, Position: time.Minute + 10*time.Second, Number: 3,
+			URI: queue.trackURI, Source: SourceQueue, Service: "Spotify", State: avt.TransportState_PLAYING,
+		}},
+		{"Radio", map[string]nowPlayingInfo{"192.168.1.100:1400": radio}, Track{
+			Title: "So What", Artist: "Miles Davis", AlbumArtURL: "http://cdn.example.com/jazz.png",
+			Position: 12 * time.Minute, URI: radio.trackURI, Source: SourceRadio, Service: "TuneIn",
+			Station: "Jazz FM", StreamContent: "Miles Davis - So What", State: avt.TransportState_PLAYING,
+		}},
+		{"LineIn", map[string]nowPlayingInfo{"192.168.1.100:1400": lineIn}, Track{
+			Title: "Turntable", URI: lineIn.trackURI, Source: SourceLineIn, State: avt.TransportState_PLAYING,
+		}},
+		{"TV", map[string]nowPlayingInfo{"192.168.1.100:1400": tv}, Track{
+			URI: tv.trackURI, Source: SourceTV, State: avt.TransportState_PLAYING,
+		}},
+		{"GroupMember", map[string]nowPlayingInfo{"192.168.1.100:1400": member, "192.168.1.101:1400": queue}, Track{
+			Title: "Song", Artist: "Artist", Album: "Album", AlbumArtist: "Album Artist",
+			AlbumArtURL: "http://192.168.1.101:1400/getaa?s=1&u=x-sonos-spotify",
+			Duration:    3*time.Minute + 25*time.Second, Position: time.Minute + 10*time.Second, Number: 3,
+			URI: queue.trackURI, Source: SourceQueue, Service: "Spotify", State: avt.TransportState_PLAYING,
+			Coordinator: "RINCON_BBB",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zp := NewMockZonePlayer(t, nowPlayingHandlers(tt.players))
+			zp.Client().Transport.(*MockRoundTripper).Descriptions = map[string]string{
+				"192.168.1.101:1400": mockDeviceDescriptionFor("Office", "RINCON_BBB"),
+			}
+
+			got, err := zp.NowPlaying(context.Background())
+			if err != nil {
+				t.Fatalf("NowPlaying failed: %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("Expected\n%+v, got\n%+v", tt.want, *got)
+			}
+		})
+	}
+}
+
+func TestTrackRadio(t *testing.T) {
+	track := Track{Station: "Station", Title: "Station", StreamContent: "TYPE=SNG|TITLE So What|ARTIST Miles Davis|ALBUM Kind of Blue"}
+	track.radio()
+	if track.Title != "So What" || track.Artist != "Miles Davis" || track.Album != "Kind of Blue" {
+		t.Errorf("Unexpected track %+v", track)
+	}
+}
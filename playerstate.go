@@ -0,0 +1,365 @@
+package sonos
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/caglar10ur/sonos/didl"
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+	rcg "github.com/caglar10ur/sonos/services/GroupRenderingControl"
+	ren "github.com/caglar10ur/sonos/services/RenderingControl"
+)
+
+type PlayerStateOption func(*PlayerState)
+
+// WithMaxPositionAge sets how long the position is extrapolated before it is queried again,
+// a minute by default.
+func WithMaxPositionAge(d time.Duration) PlayerStateOption {
+	return func(p *PlayerState) {
+		p.maxPositionAge = d
+	}
+}
+
+// PlayerTrack is the current track of a ZonePlayer.
+type PlayerTrack struct {
+	// Position in the queue, starting from 1
+	Number   int
+	URI      string
+	Duration time.Duration
+	MetaData *didl.Item
+}
+
+// EQ holds the equalizer settings of a ZonePlayer.
+type EQ struct {
+	Bass     int
+	Treble   int
+	Loudness bool
+}
+
+// PlayerState mirrors the state of a ZonePlayer from its AVTransport, RenderingControl and
+// GroupRenderingControl events so reads don't need a SOAP round trip. Reads fall back to
+// querying the device while the mirror is not Fresh.
+type PlayerState struct {
+	zp      *ZonePlayer
+	manager *SubscriptionManager
+	subs    []*SubscriptionOptions
+
+	maxPositionAge time.Duration
+
+	mu sync.RWMutex
+	// Subscriptions which received the full state of their service
+	synced    map[*SubscriptionOptions]bool
+	lastEvent time.Time
+
+	transportState avt.TransportStateEnum
+	track          PlayerTrack
+	// Position reported by the device at positionAt, zero positionAt if it needs querying
+	position    time.Duration
+	positionAt  time.Time
+	volume      int
+	muted       bool
+	eq          EQ
+	groupVolume int
+	groupMuted  bool
+}
+
+// NewPlayerState subscribes to the services of zp through m and returns the mirror of its state.
+func NewPlayerState(ctx context.Context, m *SubscriptionManager, zp *ZonePlayer, opts ...PlayerStateOption) (*PlayerState, error) {
+	p := &PlayerState{
+		zp:             zp,
+		manager:        m,
+		maxPositionAge: time.Minute,
+		synced:         make(map[*SubscriptionOptions]bool),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	for _, service := range []SonosService{zp.AVTransport, zp.RenderingControl, zp.GroupRenderingControl} {
+		sub := &SubscriptionOptions{ZonePlayer: zp, Service: service}
+		sub.EventHandler = func(evt interface{}) {
+			p.event(sub, evt)
+		}
+		if err := m.Subscribe(ctx, sub); err != nil {
+			return nil, errors.Join(err, p.Close(ctx))
+		}
+		p.mu.Lock()
+		p.subs = append(p.subs, sub)
+		p.mu.Unlock()
+	}
+	return p, nil
+}
+
+// Close unsubscribes from the services of the ZonePlayer.
+func (p *PlayerState) Close(ctx context.Context) error {
+	p.mu.Lock()
+	subs := p.subs
+	p.subs = nil
+	p.mu.Unlock()
+
+	var errs []error
+	for _, sub := range subs {
+		if err := p.manager.Unsubscribe(ctx, sub); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Fresh reports whether every subscription is active and received the full state of its service.
+func (p *PlayerState) Fresh() bool {
+	active := make(map[*SubscriptionOptions]bool)
+	for _, h := range p.manager.Health() {
+		active[h.Options] = h.State == SubscriptionActive
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.subs) == 0 {
+		return false
+	}
+	for _, sub := range p.subs {
+		if !active[sub] || !p.synced[sub] {
+			return false
+		}
+	}
+	return true
+}
+
+// LastEvent returns when the last event arrived.
+func (p *PlayerState) LastEvent() time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.lastEvent
+}
+
+func (p *PlayerState) TransportState() (avt.TransportStateEnum, error) {
+	if p.Fresh() {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		return p.transportState, nil
+	}
+
+	res, err := p.zp.AVTransport.GetTransportInfo(&avt.GetTransportInfoArgs{InstanceID: 0})
+	if err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	p.transportState = res.CurrentTransportState
+	p.mu.Unlock()
+	return res.CurrentTransportState, nil
+}
+
+func (p *PlayerState) Track() (PlayerTrack, error) {
+	if p.Fresh() {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		return p.track, nil
+	}
+
+	if err := p.queryPosition(); err != nil {
+		return PlayerTrack{}, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.track, nil
+}
+
+// Position returns the position in the current track, extrapolated from the last one the device
+// reported while playing.
+func (p *PlayerState) Position() (time.Duration, error) {
+	p.mu.RLock()
+	stale := p.positionAt.IsZero() || time.Since(p.positionAt) > p.maxPositionAge
+	p.mu.RUnlock()
+	if stale || !p.Fresh() {
+		if err := p.queryPosition(); err != nil {
+			return 0, err
+		}
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	position := p.position
+	if p.transportState == avt.TransportState_PLAYING {
+		position += time.Since(p.positionAt)
+		if p.track.Duration > 0 && position > p.track.Duration {
+			position = p.track.Duration
+		}
+	}
+	return position, nil
+}
+
+func (p *PlayerState) Volume() (int, error) {
+	if p.Fresh() {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		return p.volume, nil
+	}
+
+	volume, err := p.zp.GetVolume()
+	if err != nil {
+		return 0, err
+	}
+	p.mu.Lock()
+	p.volume = volume
+	p.mu.Unlock()
+	return volume, nil
+}
+
+func (p *PlayerState) IsMuted() (bool, error) {
+	if p.Fresh() {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		return p.muted, nil
+	}
+
+	muted, err := p.zp.IsMuted()
+	if err != nil {
+		return false, err
+	}
+	p.mu.Lock()
+	p.muted = muted
+	p.mu.Unlock()
+	return muted, nil
+}
+
+func (p *PlayerState) EQ() (EQ, error) {
+	if p.Fresh() {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		return p.eq, nil
+	}
+
+	bass, err := p.zp.RenderingControl.GetBass(&ren.GetBassArgs{InstanceID: 0})
+	if err != nil {
+		return EQ{}, err
+	}
+	treble, err := p.zp.RenderingControl.GetTreble(&ren.GetTrebleArgs{InstanceID: 0})
+	if err != nil {
+		return EQ{}, err
+	}
+	loudness, err := p.zp.RenderingControl.GetLoudness(&ren.GetLoudnessArgs{InstanceID: 0, Channel: "Master"})
+	if err != nil {
+		return EQ{}, err
+	}
+	eq := EQ{Bass: int(bass.CurrentBass), Treble: int(treble.CurrentTreble), Loudness: loudness.CurrentLoudness}
+	p.mu.Lock()
+	p.eq = eq
+	p.mu.Unlock()
+	return eq, nil
+}
+
+func (p *PlayerState) GroupVolume() (int, error) {
+	if p.Fresh() {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		return p.groupVolume, nil
+	}
+
+	volume, err := p.zp.GetGroupVolume()
+	if err != nil {
+		return 0, err
+	}
+	p.mu.Lock()
+	p.groupVolume = volume
+	p.mu.Unlock()
+	return volume, nil
+}
+
+func (p *PlayerState) IsGroupMuted() (bool, error) {
+	if p.Fresh() {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		return p.groupMuted, nil
+	}
+
+	res, err := p.zp.GroupRenderingControl.GetGroupMute(&rcg.GetGroupMuteArgs{InstanceID: 0})
+	if err != nil {
+		return false, err
+	}
+	p.mu.Lock()
+	p.groupMuted = res.CurrentMute
+	p.mu.Unlock()
+	return res.CurrentMute, nil
+}
+
+// queryPosition updates the track and the position from the device.
+func (p *PlayerState) queryPosition() error {
+	res, err := p.zp.GetPositionInfo()
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.track = PlayerTrack{
+		Number:   int(res.Track),
+		URI:      res.TrackURI,
+		Duration: parseDuration(res.TrackDuration),
+		MetaData: parseItem(res.TrackMetaData),
+	}
+	p.position = parseDuration(res.RelTime)
+	p.positionAt = time.Now()
+	return nil
+}
+
+// event updates the mirror from an event of sub.
+func (p *PlayerState) event(sub *SubscriptionOptions, evt interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastEvent = time.Now()
+
+	// The first event of a subscription carries the full state of the service
+	_, resync := evt.(ResyncEvent)
+	p.synced[sub] = !resync
+
+	switch e := evt.(type) {
+	case AVTransportLastChange:
+		c := e.Typed()
+		if c.Has("TransportState") {
+			if c.TransportState != p.transportState {
+				// The position the device reports next tells where it stopped or resumed
+				p.positionAt = time.Time{}
+			}
+			p.transportState = c.TransportState
+		}
+		if c.Has("CurrentTrack") {
+			p.track.Number = c.CurrentTrack
+		}
+		if c.Has("CurrentTrackURI") {
+			if c.CurrentTrackURI != p.track.URI {
+				p.positionAt = time.Time{}
+			}
+			p.track.URI = c.CurrentTrackURI
+		}
+		if c.Has("CurrentTrackDuration") {
+			p.track.Duration = c.CurrentTrackDuration
+		}
+		if c.Has("CurrentTrackMetaData") {
+			p.track.MetaData = c.CurrentTrackMetaData
+		}
+	case RenderingControlLastChange:
+		c := e.Typed()
+		if v, ok := c.Volume["Master"]; ok {
+			p.volume = v
+		}
+		if m, ok := c.Mute["Master"]; ok {
+			p.muted = m
+		}
+		if c.Has("Bass") {
+			p.eq.Bass = c.Bass
+		}
+		if c.Has("Treble") {
+			p.eq.Treble = c.Treble
+		}
+		if c.Has("Loudness") {
+			p.eq.Loudness = c.Loudness
+		}
+	case rcg.GroupVolume:
+		p.groupVolume = int(e)
+	case rcg.GroupMute:
+		p.groupMuted = bool(e)
+	}
+}
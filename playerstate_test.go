@@ -0,0 +1,111 @@
+package sonos
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+func TestPlayerState(t *testing.T) {
+	var calls atomic.Int32
+	counted := func(handler func(*http.Request) (*http.Response, error)) func(*http.Request) (*http.Response, error) {
+		return func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			return handler(req)
+		}
+	}
+	device := newFakeDevice(t, map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:RenderingControl:1#GetVolume":  counted(mockResponseHandler("RenderingControl", "GetVolume", "<CurrentVolume>42</CurrentVolume>")),
+		"urn:schemas-upnp-org:service:AVTransport:1#GetPositionInfo": counted(mockResponseHandler("AVTransport", "GetPositionInfo", "<Track>3</Track><TrackDuration>0:03:00</TrackDuration><RelTime>0:01:00</RelTime>")),
+	})
+	device.initialEvents = map[string]string{
+		"/MediaRenderer/AVTransport/Event":           lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/><CurrentTrack val="3"/><CurrentTrackDuration val="0:03:00"/></InstanceID></Event>`),
+		"/MediaRenderer/RenderingControl/Event":      lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/RCS/"><InstanceID val="0"><Volume channel="Master" val="25"/><Mute channel="Master" val="0"/><Bass val="2"/><Treble val="-1"/><Loudness channel="Master" val="1"/></InstanceID></Event>`),
+		"/MediaRenderer/GroupRenderingControl/Event": `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><GroupVolume>30</GroupVolume></e:property><e:property><GroupMute>1</GroupMute></e:property></e:propertyset>`,
+	}
+	zp := device.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+	m := NewSubscriptionManager(s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p, err := NewPlayerState(ctx, m, zp)
+	if err != nil {
+		t.Fatalf("NewPlayerState failed: %v", err)
+	}
+
+	if !p.Fresh() {
+		t.Fatal("Expected the state to be fresh after the initial events")
+	}
+	if state, _ := p.TransportState(); state != avt.TransportState_PLAYING {
+		t.Errorf("Unexpected transport state %s", state)
+	}
+	if volume, _ := p.Volume(); volume != 25 {
+		t.Errorf("Expected the volume of the event, got %d", volume)
+	}
+	if eq, _ := p.EQ(); eq != (EQ{Bass: 2, Treble: -1, Loudness: true}) {
+		t.Errorf("Unexpected EQ %+v", eq)
+	}
+	if volume, _ := p.GroupVolume(); volume != 30 {
+		t.Errorf("Expected the group volume of the event, got %d", volume)
+	}
+	if muted, _ := p.IsGroupMuted(); !muted {
+		t.Error("Expected the group to be muted")
+	}
+	if calls.Load() != 0 {
+		t.Errorf("Expected reads to be answered locally, got %d calls", calls.Load())
+	}
+
+	// The position is queried once and extrapolated while playing
+	first, err := p.Position()
+	if err != nil || first < time.Minute {
+		t.Fatalf("Unexpected position %s: %v", first, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if second, _ := p.Position(); second <= first {
+		t.Errorf("Expected the position to advance from %s, got %s", first, second)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected a single GetPositionInfo call, got %d", calls.Load())
+	}
+
+	status, err := device.Notify(rcSid(t, m, zp), lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/RCS/"><InstanceID val="0"><Volume channel="Master" val="12"/></InstanceID></Event>`))
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Notify failed: %d %v", status, err)
+	}
+	if volume, _ := p.Volume(); volume != 12 {
+		t.Errorf("Expected the volume to follow the event, got %d", volume)
+	}
+
+	// Without subscriptions reads go to the device
+	if err := p.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if p.Fresh() {
+		t.Error("Expected the state to be stale after Close")
+	}
+	if volume, _ := p.Volume(); volume != 42 || calls.Load() != 2 {
+		t.Errorf("Expected the volume of the device, got %d after %d calls", volume, calls.Load())
+	}
+}
+
+// rcSid returns the SID of the RenderingControl subscription of zp.
+func rcSid(t *testing.T, m *SubscriptionManager, zp *ZonePlayer) string {
+	t.Helper()
+	for _, h := range m.Health() {
+		if h.Options.Service == zp.RenderingControl {
+			return h.Sid
+		}
+	}
+	t.Fatal("No RenderingControl subscription")
+	return ""
+}
@@ -0,0 +1,115 @@
+package sonos
+
+import (
+	"fmt"
+	"strings"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+type RepeatMode string
+
+const (
+	RepeatOff RepeatMode = "off"
+	RepeatAll RepeatMode = "all"
+	// RepeatOne repeats the current track
+	RepeatOne RepeatMode = "one"
+)
+
+// ParseRepeatMode parses "off", "all" or "one", ignoring the case.
+func ParseRepeatMode(s string) (RepeatMode, error) {
+	switch r := RepeatMode(strings.ToLower(strings.TrimSpace(s))); r {
+	case RepeatOff, RepeatAll, RepeatOne:
+		return r, nil
+	}
+	return "", fmt.Errorf("invalid repeat mode %q", s)
+}
+
+// PlayMode is the play mode of the transport with shuffle and repeat as independent settings.
+type PlayMode struct {
+	Shuffle bool
+	Repeat  RepeatMode
+}
+
+// playModes maps the play modes to the values of the transport.
+var playModes = map[PlayMode]avt.CurrentPlayModeEnum{
+	{Shuffle: false, Repeat: RepeatOff}: avt.CurrentPlayMode_NORMAL,
+	{Shuffle: false, Repeat: RepeatAll}: avt.CurrentPlayMode_REPEAT_ALL,
+	{Shuffle: false, Repeat: RepeatOne}: avt.CurrentPlayMode_REPEAT_ONE,
+	{Shuffle: true, Repeat: RepeatOff}:  avt.CurrentPlayMode_SHUFFLE_NOREPEAT,
+	{Shuffle: true, Repeat: RepeatAll}:  avt.CurrentPlayMode_SHUFFLE,
+	{Shuffle: true, Repeat: RepeatOne}:  avt.CurrentPlayMode_SHUFFLE_REPEAT_ONE,
+}
+
+// NewPlayMode returns the play mode of the transport value, e.g. SHUFFLE_NOREPEAT.
+func NewPlayMode(mode avt.CurrentPlayModeEnum) (PlayMode, error) {
+	for m, v := range playModes {
+		if v == mode {
+			return m, nil
+		}
+	}
+	return PlayMode{}, fmt.Errorf("unknown play mode %q", mode)
+}
+
+// Enum returns the transport value of the play mode.
+func (m PlayMode) Enum() (avt.CurrentPlayModeEnum, error) {
+	v, ok := playModes[m]
+	if !ok {
+		return "", fmt.Errorf("invalid repeat mode %q", m.Repeat)
+	}
+	return v, nil
+}
+
+func (m PlayMode) String() string {
+	return fmt.Sprintf("shuffle %t, repeat %s", m.Shuffle, m.Repeat)
+}
+
+func (z *ZonePlayer) GetPlayMode() (PlayMode, error) {
+	res, err := z.AVTransport.GetTransportSettings(&avt.GetTransportSettingsArgs{InstanceID: 0})
+	if err != nil {
+		return PlayMode{}, err
+	}
+	return NewPlayMode(res.PlayMode)
+}
+
+func (z *ZonePlayer) SetPlayMode(m PlayMode) error {
+	mode, err := m.Enum()
+	if err != nil {
+		return err
+	}
+	_, err = z.AVTransport.SetPlayMode(&avt.SetPlayModeArgs{InstanceID: 0, NewPlayMode: mode})
+	return err
+}
+
+// SetShuffle turns shuffle on or off, keeping the repeat mode.
+func (z *ZonePlayer) SetShuffle(shuffle bool) error {
+	m, err := z.GetPlayMode()
+	if err != nil {
+		return err
+	}
+	m.Shuffle = shuffle
+	return z.SetPlayMode(m)
+}
+
+// SetRepeat sets the repeat mode, keeping shuffle.
+func (z *ZonePlayer) SetRepeat(repeat RepeatMode) error {
+	m, err := z.GetPlayMode()
+	if err != nil {
+		return err
+	}
+	m.Repeat = repeat
+	return z.SetPlayMode(m)
+}
+
+func (z *ZonePlayer) GetCrossfade() (bool, error) {
+	res, err := z.AVTransport.GetCrossfadeMode(&avt.GetCrossfadeModeArgs{InstanceID: 0})
+	if err != nil {
+		return false, err
+	}
+	return res.CrossfadeMode, nil
+}
+
+func (z *ZonePlayer) SetCrossfade(crossfade bool) error {
+	_, err := z.AVTransport.SetCrossfadeMode(&avt.SetCrossfadeModeArgs{InstanceID: 0, CrossfadeMode: crossfade})
+	return err
+}
@@ -0,0 +1,109 @@
+package sonos
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"testing"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+func TestPlayModeEnum(t *testing.T) {
+	for _, mode := range []avt.CurrentPlayModeEnum{
+		avt.CurrentPlayMode_NORMAL,
+		avt.CurrentPlayMode_REPEAT_ALL,
+		avt.CurrentPlayMode_REPEAT_ONE,
+		avt.CurrentPlayMode_SHUFFLE_NOREPEAT,
+		avt.CurrentPlayMode_SHUFFLE,
+		avt.CurrentPlayMode_SHUFFLE_REPEAT_ONE,
+	} {
+		m, err := NewPlayMode(mode)
+		if err != nil {
+			t.Fatalf("NewPlayMode(%s) failed: %v", mode, err)
+		}
+		if got, err := m.Enum(); err != nil || got != mode {
+			t.Errorf("Expected %s, got %s (%v)", mode, got, err)
+		}
+	}
+
+	if m, _ := NewPlayMode(avt.CurrentPlayMode_SHUFFLE); !m.Shuffle || m.Repeat != RepeatAll {
+		t.Errorf("Expected shuffle with repeat all, got %v", m)
+	}
+	if _, err := NewPlayMode("PARTY"); err == nil {
+		t.Error("Expected an error for an unknown play mode")
+	}
+	if _, err := (PlayMode{Repeat: "sometimes"}).Enum(); err == nil {
+		t.Error("Expected an error for an invalid repeat mode")
+	}
+	if r, err := ParseRepeatMode(" One "); err != nil || r != RepeatOne {
+		t.Errorf("Expected %s, got %s (%v)", RepeatOne, r, err)
+	}
+}
+
+func TestSetShuffleAndRepeat(t *testing.T) {
+	mode := avt.CurrentPlayMode_REPEAT_ONE
+	newPlayMode := regexp.MustCompile(`<NewPlayMode>(.*)</NewPlayMode>`)
+	handlers := map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:AVTransport:1#GetTransportSettings": func(req *http.Request) (*http.Response, error) {
+			return mockResponseHandler("AVTransport", "GetTransportSettings", "<PlayMode>"+string(mode)+"</PlayMode><RecQualityMode>NOT_IMPLEMENTED</RecQualityMode>")(req)
+		},
+		"urn:schemas-upnp-org:service:AVTransport:1#SetPlayMode": func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			if m := newPlayMode.FindSubmatch(body); m != nil {
+				mode = avt.CurrentPlayModeEnum(m[1])
+			}
+			return mockSuccessHandler("AVTransport", "SetPlayMode")(req)
+		},
+	}
+	zp := NewMockZonePlayer(t, handlers)
+
+	if err := zp.SetShuffle(true); err != nil {
+		t.Fatalf("SetShuffle failed: %v", err)
+	}
+	if mode != avt.CurrentPlayMode_SHUFFLE_REPEAT_ONE {
+		t.Errorf("Expected the repeat mode to be kept, got %s", mode)
+	}
+
+	if err := zp.SetRepeat(RepeatOff); err != nil {
+		t.Fatalf("SetRepeat failed: %v", err)
+	}
+	if mode != avt.CurrentPlayMode_SHUFFLE_NOREPEAT {
+		t.Errorf("Expected shuffle to be kept, got %s", mode)
+	}
+
+	m, err := zp.GetPlayMode()
+	if err != nil {
+		t.Fatalf("GetPlayMode failed: %v", err)
+	}
+	if m != (PlayMode{Shuffle: true, Repeat: RepeatOff}) {
+		t.Errorf("Unexpected play mode %v", m)
+	}
+}
+
+func TestCrossfade(t *testing.T) {
+	var sent string
+	crossfadeMode := regexp.MustCompile(`<CrossfadeMode>(.*)</CrossfadeMode>`)
+	handlers := map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:AVTransport:1#GetCrossfadeMode": mockResponseHandler("AVTransport", "GetCrossfadeMode", "<CrossfadeMode>1</CrossfadeMode>"),
+		"urn:schemas-upnp-org:service:AVTransport:1#SetCrossfadeMode": func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			if m := crossfadeMode.FindSubmatch(body); m != nil {
+				sent = string(m[1])
+			}
+			return mockSuccessHandler("AVTransport", "SetCrossfadeMode")(req)
+		},
+	}
+	zp := NewMockZonePlayer(t, handlers)
+
+	crossfade, err := zp.GetCrossfade()
+	if err != nil || !crossfade {
+		t.Errorf("Expected crossfade to be on, got %t (%v)", crossfade, err)
+	}
+	if err := zp.SetCrossfade(false); err != nil {
+		t.Fatalf("SetCrossfade failed: %v", err)
+	}
+	if sent != "false" && sent != "0" {
+		t.Errorf("Expected crossfade to be turned off, sent %q", sent)
+	}
+}
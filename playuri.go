@@ -0,0 +1,253 @@
+package sonos
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/caglar10ur/sonos/didl"
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+// URIKind is the kind of a URI given to PlayURI.
+type URIKind int
+
+const (
+	URIKindUnknown URIKind = iota
+	// An audio file served over HTTP, e.g. http://example.com/song.mp3
+	URIKindFile
+	// An internet radio stream served over HTTP(S), played as x-rincon-mp3radio unless it is HTTPS
+	URIKindStream
+	// e.g. x-rincon-mp3radio://example.com/stream
+	URIKindMP3Radio
+	// A station of a music service, e.g. x-sonosapi-stream:s1234?sid=254
+	URIKindSonosAPIStream
+	// A file of a music library share, e.g. x-file-cifs://nas/music/song.flac
+	URIKindCIFS
+)
+
+func (k URIKind) String() string {
+	switch k {
+	case URIKindFile:
+		return "file"
+	case URIKindStream:
+		return "stream"
+	case URIKindMP3Radio:
+		return "mp3radio"
+	case URIKindSonosAPIStream:
+		return "sonosapi-stream"
+	case URIKindCIFS:
+		return "cifs"
+	}
+	return "unknown"
+}
+
+// PlayURIOptions are the options of PlayURI.
+type PlayURIOptions struct {
+	// Shown by the players, derived from the URI by default
+	Title       string
+	Artist      string
+	AlbumArtURI string
+	// Detected from the URI by default
+	Kind URIKind
+	// Adds files to the end of the queue without playing them, streams can't be enqueued
+	Enqueue bool
+}
+
+const (
+	classMusicTrack     = "object.item.audioItem.musicTrack"
+	classAudioBroadcast = "object.item.audioItem.audioBroadcast"
+	// TuneIn
+	defaultServiceID = 254
+)
+
+// MIME types of the audio files by their extension.
+var audioTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".flac": "audio/flac",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+	".wav":  "audio/wav",
+}
+
+// DetectURIKind returns the kind of the URI, URIKindUnknown if PlayURI can't play it. HTTP URIs
+// are files if they have the extension of an audio file, streams otherwise.
+func DetectURIKind(uri string) URIKind {
+	scheme, rest, ok := strings.Cut(uri, ":")
+	if !ok {
+		return URIKindUnknown
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https":
+		u, err := url.Parse(uri)
+		if err != nil {
+			return URIKindUnknown
+		}
+		if _, ok := audioTypes[strings.ToLower(path.Ext(u.Path))]; ok {
+			return URIKindFile
+		}
+		return URIKindStream
+	case "x-rincon-mp3radio":
+		return URIKindMP3Radio
+	case "x-sonosapi-stream":
+		return URIKindSonosAPIStream
+	case "x-file-cifs":
+		if rest != "" {
+			return URIKindCIFS
+		}
+	}
+	return URIKindUnknown
+}
+
+// PlayURI plays the URI with the metadata the players expect for its kind. Files are added to
+// the queue, which the player switches to, and streams replace the current media. opts may be
+// nil.
+func (z *ZonePlayer) PlayURI(ctx context.Context, uri string, opts *PlayURIOptions) error {
+	if opts == nil {
+		opts = &PlayURIOptions{}
+	}
+	kind := opts.Kind
+	if kind == URIKindUnknown {
+		kind = DetectURIKind(uri)
+	}
+	if kind == URIKindUnknown {
+		return fmt.Errorf("unsupported URI %q", uri)
+	}
+
+	uri, item := uriItem(uri, kind, opts)
+	metadata, err := didl.Document(item)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case URIKindFile, URIKindCIFS:
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		res, err := z.AVTransport.AddURIToQueue(&avt.AddURIToQueueArgs{InstanceID: 0, EnqueuedURI: uri, EnqueuedURIMetaData: metadata})
+		if err != nil {
+			return err
+		}
+		if opts.Enqueue {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := z.SwitchToQueue(); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := z.AVTransport.Seek(&avt.SeekArgs{InstanceID: 0, Unit: avt.SeekMode_TRACK_NR, Target: strconv.Itoa(int(res.FirstTrackNumberEnqueued))}); err != nil {
+			return err
+		}
+	default:
+		if opts.Enqueue {
+			return fmt.Errorf("%s URI %q can't be enqueued", kind, uri)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := z.AVTransport.SetAVTransportURI(&avt.SetAVTransportURIArgs{InstanceID: 0, CurrentURI: uri, CurrentURIMetaData: metadata}); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return z.Play()
+}
+
+// uriItem returns the URI to play, rewritten for streams, and its DIDL item.
+func uriItem(uri string, kind URIKind, opts *PlayURIOptions) (string, didl.Item) {
+	item := didl.Item{ID: "-1", ParentID: "-1", Restricted: true}
+	title := opts.Title
+
+	switch kind {
+	case URIKindFile, URIKindCIFS:
+		scheme := "http-get"
+		if kind == URIKindCIFS {
+			scheme = "x-file-cifs"
+		}
+		if u, err := url.Parse(uri); err == nil && title == "" {
+			title = strings.TrimSuffix(path.Base(u.Path), path.Ext(u.Path))
+		}
+		item.Class = []didl.Class{{Value: classMusicTrack}}
+		item.Res = []didl.Res{{ProtocolInfo: scheme + ":*:" + audioType(uri) + ":*", Value: uri}}
+
+	case URIKindStream, URIKindMP3Radio:
+		if u, err := url.Parse(uri); err == nil && title == "" {
+			title = u.Host
+		}
+		// MP3 radio doesn't support HTTPS, such streams are played as plain HTTP resources
+		if strings.HasPrefix(uri, "https://") {
+			item.Class = []didl.Class{{Value: classMusicTrack}}
+			item.Res = []didl.Res{{ProtocolInfo: "http-get:*:" + audioType(uri) + ":*", Value: uri}}
+			break
+		}
+		// Plain HTTP streams play as MP3 radio
+		if rest, ok := strings.CutPrefix(uri, "http://"); ok {
+			uri = "x-rincon-mp3radio://" + rest
+		}
+		item.ID, item.ParentID = "R:0/0/0", "R:0/0"
+		item.Class = []didl.Class{{Value: classAudioBroadcast}}
+		item.Res = []didl.Res{{ProtocolInfo: "x-rincon-mp3radio:*:*:*", Value: uri}}
+		item.Desc = []didl.Desc{serviceDesc(defaultServiceID)}
+
+	case URIKindSonosAPIStream:
+		// e.g. x-sonosapi-stream:s1234?sid=254&flags=8224&sn=0
+		station, query, _ := strings.Cut(strings.TrimPrefix(uri, "x-sonosapi-stream:"), "?")
+		sid := defaultServiceID
+		if values, err := url.ParseQuery(query); err == nil {
+			if n, err := strconv.Atoi(values.Get("sid")); err == nil {
+				sid = n
+			}
+		}
+		if title == "" {
+			title = station
+		}
+		item.ID, item.ParentID = "F00092020"+station, "L"
+		item.Class = []didl.Class{{Value: classAudioBroadcast}}
+		item.Desc = []didl.Desc{serviceDesc(sid)}
+	}
+
+	if title == "" {
+		title = uri
+	}
+	item.Title = []didl.Title{{Value: title}}
+	if opts.Artist != "" {
+		item.Creator = []didl.Creator{{Value: opts.Artist}}
+	}
+	if opts.AlbumArtURI != "" {
+		item.AlbumArtURI = []didl.AlbumArtURI{{Value: opts.AlbumArtURI}}
+	}
+	return uri, item
+}
+
+// audioType returns the MIME type of the audio file the URI points to, * if unknown.
+func audioType(uri string) string {
+	if u, err := url.Parse(uri); err == nil {
+		if t, ok := audioTypes[strings.ToLower(path.Ext(u.Path))]; ok {
+			return t
+		}
+	}
+	return "*"
+}
+
+// serviceDesc returns the desc the players expect for items of the music service.
+func serviceDesc(sid int) didl.Desc {
+	return didl.Desc{
+		ID:        "cdudn",
+		NameSpace: "urn:schemas-rinconnetworks-com:metadata-1-0/",
+		// The account of the service type, 256 * sid + 7
+		Value: fmt.Sprintf("SA_RINCON%d_", sid*256+7),
+	}
+}
@@ -0,0 +1,163 @@
+package sonos
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/caglar10ur/sonos/didl"
+)
+
+func TestDetectURIKind(t *testing.T) {
+	for uri, want := range map[string]URIKind{
+		"http://example.com/music/song.mp3":          URIKindFile,
+		"https://example.com/song.FLAC?token=1":      URIKindFile,
+		"http://radio.example.com:8000/live":         URIKindStream,
+		"https://radio.example.com/playlist.m3u":     URIKindStream,
+		"x-rincon-mp3radio://radio.example.com/live": URIKindMP3Radio,
+		"x-sonosapi-stream:s1234?sid=254&flags=8224": URIKindSonosAPIStream,
+		"x-file-cifs://nas/music/song.flac":          URIKindCIFS,
+		"x-rincon-queue:RINCON_000E58CDCA4001400#0":  URIKindUnknown,
+		"song.mp3": URIKindUnknown,
+	} {
+		if got := DetectURIKind(uri); got != want {
+			t.Errorf("DetectURIKind(%q): expected %s, got %s", uri, want, got)
+		}
+	}
+}
+
+func TestPlayURI(t *testing.T) {
+	// calls records the actions with their URI and the class, protocolInfo and title of its metadata
+	playURIHandlers := func(calls *[]string) map[string]func(*http.Request) (*http.Response, error) {
+		record := func(action, uriTag, metadataTag, response string) func(*http.Request) (*http.Response, error) {
+			args := regexp.MustCompile(`<` + uriTag + `>(.*)</` + uriTag + `><` + metadataTag + `>(.*)</` + metadataTag + `>`)
+			return func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				call := action
+				if m := args.FindSubmatch(body); m != nil {
+					call += " " + unescapeXML(t, string(m[1]))
+					if item := parseItem(unescapeXML(t, string(m[2]))); item != nil {
+						call += " " + didl.First(item.Class) + " " + didl.First(item.Title)
+						if len(item.Res) > 0 {
+							call += " " + item.Res[0].ProtocolInfo
+						}
+						if len(item.Desc) > 0 {
+							call += " " + item.Desc[0].Value
+						}
+					}
+				}
+				*calls = append(*calls, call)
+				return mockResponseHandler("AVTransport", action, response)(req)
+			}
+		}
+		seek := regexp.MustCompile(`<Unit>(.*)</Unit><Target>(.*)</Target>`)
+		return map[string]func(*http.Request) (*http.Response, error){
+			"urn:schemas-upnp-org:service:AVTransport:1#AddURIToQueue":     record("AddURIToQueue", "EnqueuedURI", "EnqueuedURIMetaData", "<FirstTrackNumberEnqueued>4</FirstTrackNumberEnqueued><NumTracksAdded>1</NumTracksAdded><NewQueueLength>4</NewQueueLength>"),
+			"urn:schemas-upnp-org:service:AVTransport:1#SetAVTransportURI": record("SetAVTransportURI", "CurrentURI", "CurrentURIMetaData", ""),
+			"urn:schemas-upnp-org:service:AVTransport:1#Seek": func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				if m := seek.FindSubmatch(body); m != nil {
+					*calls = append(*calls, "Seek "+string(m[1])+" "+string(m[2]))
+				}
+				return mockSuccessHandler("AVTransport", "Seek")(req)
+			},
+			"urn:schemas-upnp-org:service:AVTransport:1#Play": func(req *http.Request) (*http.Response, error) {
+				*calls = append(*calls, "Play")
+				return mockSuccessHandler("AVTransport", "Play")(req)
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		uri  string
+		opts *PlayURIOptions
+		want []string
+	}{
+		{"File", "http://example.com/music/song.mp3", nil, []string{
+			"AddURIToQueue http://example.com/music/song.mp3 object.item.audioItem.musicTrack song http-get:*:audio/mpeg:*",
+			"SetAVTransportURI x-rincon-queue:RINCON_000E58CDCA4001400#0",
+			"Seek TRACK_NR 4",
+			"Play",
+		}},
+		{"Enqueue", "http://example.com/music/song.flac", &PlayURIOptions{Title: "Song", Enqueue: true}, []string{
+			"AddURIToQueue http://example.com/music/song.flac object.item.audioItem.musicTrack Song http-get:*:audio/flac:*",
+		}},
+		{"CIFS", "x-file-cifs://nas/music/song.m4a", nil, []string{
+			"AddURIToQueue x-file-cifs://nas/music/song.m4a object.item.audioItem.musicTrack song x-file-cifs:*:audio/mp4:*",
+			"SetAVTransportURI x-rincon-queue:RINCON_000E58CDCA4001400#0",
+			"Seek TRACK_NR 4",
+			"Play",
+		}},
+		{"Stream", "http://radio.example.com:8000/live", nil, []string{
+			"SetAVTransportURI x-rincon-mp3radio://radio.example.com:8000/live object.item.audioItem.audioBroadcast radio.example.com:8000 x-rincon-mp3radio:*:*:* SA_RINCON65031_",
+			"Play",
+		}},
+		{"HTTPSStream", "https://radio.example.com/live", nil, []string{
+			"SetAVTransportURI https://radio.example.com/live object.item.audioItem.musicTrack radio.example.com http-get:*:*:*",
+			"Play",
+		}},
+		{"MP3Radio", "x-rincon-mp3radio://radio.example.com/live", &PlayURIOptions{Title: "Jazz FM"}, []string{
+			"SetAVTransportURI x-rincon-mp3radio://radio.example.com/live object.item.audioItem.audioBroadcast Jazz FM x-rincon-mp3radio:*:*:* SA_RINCON65031_",
+			"Play",
+		}},
+		{"SonosAPIStream", "x-sonosapi-stream:s1234?sid=254&flags=8224&sn=0", &PlayURIOptions{Title: "Jazz FM"}, []string{
+			"SetAVTransportURI x-sonosapi-stream:s1234?sid=254&flags=8224&sn=0 object.item.audioItem.audioBroadcast Jazz FM SA_RINCON65031_",
+			"Play",
+		}},
+		{"Kind", "http://example.com/download?id=1", &PlayURIOptions{Kind: URIKindFile}, []string{
+			"AddURIToQueue http://example.com/download?id=1 object.item.audioItem.musicTrack download http-get:*:*:*",
+			"SetAVTransportURI x-rincon-queue:RINCON_000E58CDCA4001400#0",
+			"Seek TRACK_NR 4",
+			"Play",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			zp := NewMockZonePlayer(t, playURIHandlers(&calls))
+			if err := zp.PlayURI(context.Background(), tt.uri, tt.opts); err != nil {
+				t.Fatalf("PlayURI failed: %v", err)
+			}
+			if len(calls) != len(tt.want) {
+				t.Fatalf("Expected %q, got %q", tt.want, calls)
+			}
+			for i := range calls {
+				if calls[i] != tt.want[i] {
+					t.Errorf("Expected %q, got %q", tt.want[i], calls[i])
+				}
+			}
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		var calls []string
+		zp := NewMockZonePlayer(t, playURIHandlers(&calls))
+		if err := zp.PlayURI(context.Background(), "spotify:track:123", nil); err == nil {
+			t.Error("Expected an error for an unsupported URI")
+		}
+		if err := zp.PlayURI(context.Background(), "http://radio.example.com/live", &PlayURIOptions{Enqueue: true}); err == nil {
+			t.Error("Expected an error for enqueueing a stream")
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := zp.PlayURI(ctx, "http://example.com/song.mp3", nil); err != context.Canceled {
+			t.Errorf("Expected %v, got %v", context.Canceled, err)
+		}
+		if len(calls) != 0 {
+			t.Errorf("Unexpected calls %q", calls)
+		}
+	})
+}
+
+func unescapeXML(t *testing.T, s string) string {
+	t.Helper()
+	var v string
+	if err := xml.Unmarshal([]byte("<v>"+s+"</v>"), &v); err != nil {
+		t.Fatalf("Unescaping %q failed: %v", s, err)
+	}
+	return v
+}
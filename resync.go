@@ -0,0 +1,230 @@
+package sonos
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+// ResyncEvent is delivered when events of a subscription were missed or arrived out of order.
+// It is followed by an event carrying the full state of the service, either synthesized from
+// the current state of the device or sent by the device for a new subscription.
+type ResyncEvent struct {
+	Sid      string
+	Expected uint32
+	Received uint32
+}
+
+// sequence tracks the SEQ of the events of a subscription.
+type sequence struct {
+	mu   sync.Mutex
+	next uint32
+}
+
+// advance records seq and returns the expected one and whether they match.
+func (q *sequence) advance(seq uint32) (uint32, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	expected := q.next
+	q.next = seq + 1
+	// SEQ wraps around to 1 as 0 is reserved for the initial event
+	if q.next == 0 {
+		q.next = 1
+	}
+	return expected, seq == expected
+}
+
+// deliver dispatches the event notification and resyncs the subscription if its SEQ is off.
+func (s *Sonos) deliver(opts *SubscriptionOptions, sid, seq string, data []byte) {
+	s.dispatch(opts, sid, seq, data)
+
+	n, err := strconv.ParseUint(seq, 10, 32)
+	if err != nil {
+		return
+	}
+	v, ok := s.sequences.Load(sid)
+	if !ok {
+		return
+	}
+	// Watchers, the journal and the forwarder rely on the state as much as the handler
+	expected, ok := v.(*sequence).advance(uint32(n))
+	if ok {
+		return
+	}
+
+	s.emit(opts, sid, "", ResyncEvent{Sid: sid, Expected: expected, Received: uint32(n)})
+	s.scheduleResync(opts, sid)
+}
+
+// scheduleResync resyncs the subscription unless a resync of it is in progress already, which
+// then runs once more as it may have missed the latest changes.
+func (s *Sonos) scheduleResync(opts *SubscriptionOptions, sid string) {
+	s.resyncMu.Lock()
+	defer s.resyncMu.Unlock()
+	if _, ok := s.resyncs[opts]; ok {
+		s.resyncs[opts] = sid
+		return
+	}
+	if s.resyncs == nil {
+		s.resyncs = make(map[*SubscriptionOptions]string)
+	}
+	s.resyncs[opts] = ""
+
+	go func() {
+		for sid != "" {
+			s.resync(opts, sid)
+
+			s.resyncMu.Lock()
+			sid = s.resyncs[opts]
+			if sid == "" {
+				delete(s.resyncs, opts)
+			} else {
+				s.resyncs[opts] = ""
+			}
+			s.resyncMu.Unlock()
+		}
+	}()
+}
+
+// resync delivers the full state of the subscribed service. Services without a way to query
+// their state are resubscribed, which makes the device send its full state again.
+func (s *Sonos) resync(opts *SubscriptionOptions, sid string) {
+	zp := opts.ZonePlayer
+
+	var evt interface{}
+	var err error
+	switch path := opts.Service.EventEndpoint().Path; {
+	case opts.GenericLastChange && path != zp.ZoneGroupTopology.EventEndpoint().Path:
+		// The state is synthesized as the per service types, let the device send it instead
+		s.resubscribe(opts)
+		return
+	case path == zp.AVTransport.EventEndpoint().Path:
+		evt, err = zp.avTransportState()
+	case path == zp.RenderingControl.EventEndpoint().Path:
+		evt, err = zp.renderingControlState()
+	case path == zp.ZoneGroupTopology.EventEndpoint().Path:
+		evt, err = zp.zoneGroupTopologyState()
+	default:
+		s.resubscribe(opts)
+		return
+	}
+	if err != nil {
+		s.logger.Warn("failed to query state, resubscribing", "sid", sid, "error", err)
+		s.resubscribe(opts)
+		return
+	}
+	s.emit(opts, sid, "", evt)
+	if _, ok := evt.(AVTransportLastChange); ok {
+		// The sleep timer may have changed unnoticed as well
+		s.emitSleepTimer(opts, sid)
+	}
+}
+
+// resubscribe replaces the subscription by a new one, through its SubscriptionManager if any.
+func (s *Sonos) resubscribe(opts *SubscriptionOptions) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var managed bool
+	var err error
+	s.managers.Range(func(key, _ any) bool {
+		managed, err = key.(*SubscriptionManager).resubscribe(ctx, opts)
+		return !managed
+	})
+	if managed {
+		if err != nil {
+			s.logger.Error("failed to resubscribe", "error", err)
+		}
+		return
+	}
+
+	// The device may have dropped the subscription already
+	_ = s.Unsubscribe(ctx, opts)
+	if _, err := s.Subscribe(ctx, opts); err != nil {
+		s.logger.Error("failed to resubscribe", "error", err)
+	}
+}
+
+// avTransportState returns the current state of the AVTransport service as a LastChange event.
+func (z *ZonePlayer) avTransportState() (AVTransportLastChange, error) {
+	var evt AVTransportLastChange
+
+	transport, err := z.AVTransport.GetTransportInfo(&avt.GetTransportInfoArgs{InstanceID: 0})
+	if err != nil {
+		return evt, err
+	}
+	position, err := z.GetPositionInfo()
+	if err != nil {
+		return evt, err
+	}
+	media, err := z.AVTransport.GetMediaInfo(&avt.GetMediaInfoArgs{InstanceID: 0})
+	if err != nil {
+		return evt, err
+	}
+	settings, err := z.AVTransport.GetTransportSettings(&avt.GetTransportSettingsArgs{InstanceID: 0})
+	if err != nil {
+		return evt, err
+	}
+
+	i := &evt.InstanceID
+	i.TransportState.Value = string(transport.CurrentTransportState)
+	i.TransportStatus.Value = transport.CurrentTransportStatus
+	i.TransportPlaySpeed.Value = string(transport.CurrentSpeed)
+	i.CurrentPlayMode.Value = string(settings.PlayMode)
+	i.NumberOfTracks.Value = strconv.FormatUint(uint64(media.NrTracks), 10)
+	i.CurrentMediaDuration.Value = media.MediaDuration
+	i.AVTransportURI.Value = media.CurrentURI
+	i.AVTransportURIMetaData.Value = media.CurrentURIMetaData
+	i.NextAVTransportURI.Value = media.NextURI
+	i.NextAVTransportURIMetaData.Value = media.NextURIMetaData
+	i.PlaybackStorageMedium.Value = string(media.PlayMedium)
+	i.CurrentTrack.Value = strconv.FormatUint(uint64(position.Track), 10)
+	i.CurrentTrackURI.Value = position.TrackURI
+	i.CurrentTrackDuration.Value = position.TrackDuration
+	i.CurrentTrackMetaData.Value = position.TrackMetaData
+	i.present = presentSet([]string{
+		"TransportState", "TransportStatus", "TransportPlaySpeed", "CurrentPlayMode", "NumberOfTracks",
+		"CurrentMediaDuration", "AVTransportURI", "AVTransportURIMetaData", "NextAVTransportURI",
+		"NextAVTransportURIMetaData", "PlaybackStorageMedium", "CurrentTrack", "CurrentTrackURI",
+		"CurrentTrackDuration", "CurrentTrackMetaData",
+	})
+
+	return evt, nil
+}
+
+// renderingControlState returns the current volume and mute state as a LastChange event.
+func (z *ZonePlayer) renderingControlState() (RenderingControlLastChange, error) {
+	var evt RenderingControlLastChange
+
+	volume, err := z.GetVolume()
+	if err != nil {
+		return evt, err
+	}
+	muted, err := z.IsMuted()
+	if err != nil {
+		return evt, err
+	}
+
+	mute := "0"
+	if muted {
+		mute = "1"
+	}
+	evt.InstanceID.Volume = []Volume{{Channel: "Master", Value: strconv.Itoa(volume)}}
+	evt.InstanceID.Mute = []Mute{{Channel: "Master", Value: mute}}
+	evt.InstanceID.present = presentSet([]string{"Volume", "Mute"})
+
+	return evt, nil
+}
+
+// zoneGroupTopologyState returns the current topology as a ZoneGroupState event.
+func (z *ZonePlayer) zoneGroupTopologyState() (ZoneGroupTopologyZoneGroupState, error) {
+	zoneGroupState, err := z.GetZoneGroupState()
+	if err != nil {
+		return ZoneGroupTopologyZoneGroupState{}, err
+	}
+	return ZoneGroupTopologyZoneGroupState{ZoneGroups: ZoneGroups{ZoneGroup: zoneGroupState.ZoneGroups}}, nil
+}
@@ -0,0 +1,165 @@
+package sonos
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResyncOnSeqGap(t *testing.T) {
+	handlers := map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:RenderingControl:1#GetVolume": mockResponseHandler("RenderingControl", "GetVolume", "<CurrentVolume>42</CurrentVolume>"),
+		"urn:schemas-upnp-org:service:RenderingControl:1#GetMute":   mockResponseHandler("RenderingControl", "GetMute", "<CurrentMute>1</CurrentMute>"),
+	}
+	device := newFakeDevice(t, handlers)
+	zp := device.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	events := make(chan interface{}, 10)
+	opts := &SubscriptionOptions{
+		ZonePlayer: zp,
+		Service:    zp.RenderingControl,
+		EventHandler: func(evt interface{}) {
+			events <- evt
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sid, err := s.Subscribe(ctx, opts)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	next := func() interface{} {
+		t.Helper()
+		select {
+		case evt := <-events:
+			return evt
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for an event")
+			return nil
+		}
+	}
+
+	volume := lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/RCS/"><InstanceID val="0"><Volume channel="Master" val="10"/></InstanceID></Event>`)
+	if _, err := device.Notify(sid, volume); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if _, ok := next().(RenderingControlLastChange); !ok {
+		t.Fatal("Expected the initial event")
+	}
+
+	device.Skip(sid)
+	if _, err := device.Notify(sid, volume); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if _, ok := next().(RenderingControlLastChange); !ok {
+		t.Fatal("Expected the event after the gap to be delivered")
+	}
+
+	resync, ok := next().(ResyncEvent)
+	if !ok || resync.Sid != sid || resync.Expected != 1 || resync.Received != 2 {
+		t.Fatalf("Unexpected resync event: %+v", resync)
+	}
+
+	state, ok := next().(RenderingControlLastChange)
+	if !ok || len(state.InstanceID.Volume) != 1 || state.InstanceID.Volume[0].Value != "42" || state.InstanceID.Mute[0].Value != "1" {
+		t.Errorf("Unexpected full state event: %+v", state)
+	}
+	// The EQ wasn't queried, it must not look like it was reset
+	if c := state.Typed(); !c.Has("Volume") || !c.Has("Mute") || c.Has("Bass") || c.Has("Loudness") {
+		t.Errorf("Unexpected presence: %v", c.present)
+	}
+}
+
+func TestResyncWithoutHandler(t *testing.T) {
+	handlers := map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:RenderingControl:1#GetVolume": mockResponseHandler("RenderingControl", "GetVolume", "<CurrentVolume>42</CurrentVolume>"),
+		"urn:schemas-upnp-org:service:RenderingControl:1#GetMute":   mockResponseHandler("RenderingControl", "GetMute", "<CurrentMute>0</CurrentMute>"),
+	}
+	device := newFakeDevice(t, handlers)
+	zp := device.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	// Watchers rely on the resync like handlers do
+	events := make(chan interface{}, 10)
+	defer zp.watch(func(evt interface{}) {
+		events <- evt
+	})()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sid, err := s.Subscribe(ctx, &SubscriptionOptions{ZonePlayer: zp, Service: zp.RenderingControl})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	volume := lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/RCS/"><InstanceID val="0"><Volume channel="Master" val="10"/></InstanceID></Event>`)
+	for range 2 {
+		if _, err := device.Notify(sid, volume); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+		device.Skip(sid)
+	}
+
+	for {
+		select {
+		case evt := <-events:
+			if state, ok := evt.(RenderingControlLastChange); ok && len(state.InstanceID.Volume) == 1 && state.InstanceID.Volume[0].Value == "42" {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("Expected the full state after the gap")
+		}
+	}
+}
+
+func TestResyncManagedSubscription(t *testing.T) {
+	device := newFakeDevice(t, nil)
+	device.timeout = 1
+	zp := device.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	m := NewSubscriptionManager(s, WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+	opts := &SubscriptionOptions{ZonePlayer: zp, Service: zp.GroupRenderingControl}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Subscribe(ctx, opts); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	sid := m.Health()[0].Sid
+
+	// Gaps while the manager renews it resubscribe it through the manager, one at a time
+	volume := `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><GroupVolume>10</GroupVolume></e:property></e:propertyset>`
+	for range 3 {
+		device.Skip(sid)
+		// Fails once the old subscription is gone
+		_, _ = device.Notify(sid, volume)
+	}
+
+	waitUntil(t, "resubscription", func() bool {
+		health := m.Health()
+		sids := device.Sids()
+		return len(health) == 1 && health[0].State == SubscriptionActive && health[0].Sid != sid &&
+			len(sids) == 1 && sids[0] == health[0].Sid
+	})
+	waitUntil(t, "renewal", func() bool { return device.Renewals() > 0 })
+}
@@ -0,0 +1,111 @@
+package sonos
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+// NotSeekableError is returned when the current source can't be seeked, e.g. a radio stream.
+type NotSeekableError struct {
+	Unit avt.SeekModeEnum
+	// Actions the transport currently allows
+	Actions []string
+}
+
+func (e *NotSeekableError) Error() string {
+	return fmt.Sprintf("current source is not seekable by %s, allowed actions: %v", e.Unit, e.Actions)
+}
+
+// Transport actions the players report when the current source can be seeked by the unit.
+var seekActions = map[avt.SeekModeEnum]string{
+	avt.SeekMode_REL_TIME: "X_DLNA_SeekTime",
+	avt.SeekMode_TRACK_NR: "X_DLNA_SeekTrackNr",
+}
+
+// Seek moves to the position in the current track, clamped to its duration.
+func (z *ZonePlayer) Seek(ctx context.Context, position time.Duration) error {
+	return z.seekTime(ctx, func(time.Duration) time.Duration {
+		return position
+	})
+}
+
+// SeekRelative moves delta away from the current position in the current track, clamped to its
+// start and duration.
+func (z *ZonePlayer) SeekRelative(ctx context.Context, delta time.Duration) error {
+	return z.seekTime(ctx, func(current time.Duration) time.Duration {
+		return current + delta
+	})
+}
+
+// SeekTrack moves to the track of the queue, starting from 1.
+func (z *ZonePlayer) SeekTrack(ctx context.Context, track int) error {
+	if track < 1 {
+		return fmt.Errorf("invalid track %d", track)
+	}
+	if err := z.seekable(ctx, avt.SeekMode_TRACK_NR); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err := z.AVTransport.Seek(&avt.SeekArgs{InstanceID: 0, Unit: avt.SeekMode_TRACK_NR, Target: strconv.Itoa(track)})
+	return err
+}
+
+// Restart moves to the start of the current track.
+func (z *ZonePlayer) Restart(ctx context.Context) error {
+	return z.Seek(ctx, 0)
+}
+
+// seekTime moves to the position target returns for the current one.
+func (z *ZonePlayer) seekTime(ctx context.Context, target func(current time.Duration) time.Duration) error {
+	if err := z.seekable(ctx, avt.SeekMode_REL_TIME); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	res, err := z.GetPositionInfo()
+	if err != nil {
+		return err
+	}
+
+	position := max(target(parseDuration(res.RelTime)), 0)
+	// Streams report no duration
+	if duration := parseDuration(res.TrackDuration); duration > 0 {
+		position = min(position, duration)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err = z.AVTransport.Seek(&avt.SeekArgs{InstanceID: 0, Unit: avt.SeekMode_REL_TIME, Target: formatDuration(position)})
+	return err
+}
+
+// seekable returns a NotSeekableError unless the transport currently allows seeking.
+func (z *ZonePlayer) seekable(ctx context.Context, unit avt.SeekModeEnum) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	res, err := z.AVTransport.GetCurrentTransportActions(&avt.GetCurrentTransportActionsArgs{InstanceID: 0})
+	if err != nil {
+		return err
+	}
+	actions := parseList(res.Actions)
+	if slices.Contains(actions, seekActions[unit]) {
+		return nil
+	}
+	return &NotSeekableError{Unit: unit, Actions: actions}
+}
+
+// formatDuration formats the duration as H:MM:SS, the format of the time based seek targets.
+func formatDuration(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
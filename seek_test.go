@@ -0,0 +1,104 @@
+package sonos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                                 "0:00:00",
+		3*time.Minute + 25*time.Second:    "0:03:25",
+		time.Hour + 2*time.Second + 900e6: "1:00:02",
+		26 * time.Hour:                    "26:00:00",
+	} {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%v): expected %s, got %s", d, want, got)
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+	seekHandlers := func(actions, relTime, duration string, targets *[]string) map[string]func(*http.Request) (*http.Response, error) {
+		target := regexp.MustCompile(`<Unit>(.*)</Unit><Target>(.*)</Target>`)
+		return map[string]func(*http.Request) (*http.Response, error){
+			"urn:schemas-upnp-org:service:AVTransport:1#GetCurrentTransportActions": mockResponseHandler("AVTransport", "GetCurrentTransportActions", "<Actions>"+actions+"</Actions>"),
+			"urn:schemas-upnp-org:service:AVTransport:1#GetPositionInfo":            mockResponseHandler("AVTransport", "GetPositionInfo", "<Track>3</Track><TrackDuration>"+duration+"</TrackDuration><RelTime>"+relTime+"</RelTime>"),
+			"urn:schemas-upnp-org:service:AVTransport:1#Seek": func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				if m := target.FindSubmatch(body); m != nil {
+					*targets = append(*targets, string(m[1])+" "+string(m[2]))
+				}
+				return mockSuccessHandler("AVTransport", "Seek")(req)
+			},
+		}
+	}
+	ctx := context.Background()
+	const actions = "Set, Stop, Pause, Play, X_DLNA_SeekTime, Next, X_DLNA_SeekTrackNr"
+
+	tests := []struct {
+		name string
+		call func(*ZonePlayer) error
+		want string
+	}{
+		{"Seek", func(zp *ZonePlayer) error { return zp.Seek(ctx, 90*time.Second) }, "REL_TIME 0:01:30"},
+		{"SeekPastEnd", func(zp *ZonePlayer) error { return zp.Seek(ctx, time.Hour) }, "REL_TIME 0:03:25"},
+		{"SeekRelative", func(zp *ZonePlayer) error { return zp.SeekRelative(ctx, 30*time.Second) }, "REL_TIME 0:01:30"},
+		{"SeekRelativeBeforeStart", func(zp *ZonePlayer) error { return zp.SeekRelative(ctx, -2*time.Minute) }, "REL_TIME 0:00:00"},
+		{"SeekTrack", func(zp *ZonePlayer) error { return zp.SeekTrack(ctx, 5) }, "TRACK_NR 5"},
+		{"Restart", func(zp *ZonePlayer) error { return zp.Restart(ctx) }, "REL_TIME 0:00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var targets []string
+			zp := NewMockZonePlayer(t, seekHandlers(actions, "0:01:00", "0:03:25", &targets))
+			if err := tt.call(zp); err != nil {
+				t.Fatalf("%s failed: %v", tt.name, err)
+			}
+			if len(targets) != 1 || targets[0] != tt.want {
+				t.Errorf("Expected %s, got %v", tt.want, targets)
+			}
+		})
+	}
+
+	t.Run("Stream", func(t *testing.T) {
+		var targets []string
+		zp := NewMockZonePlayer(t, seekHandlers("Set, Stop, Pause, Play", "0:12:00", "0:00:00", &targets))
+
+		err := zp.Seek(ctx, time.Minute)
+		var notSeekable *NotSeekableError
+		if !errors.As(err, &notSeekable) || notSeekable.Unit != "REL_TIME" || len(notSeekable.Actions) != 4 {
+			t.Errorf("Expected a NotSeekableError, got %v", err)
+		}
+		if len(targets) != 0 {
+			t.Errorf("Expected no seek, got %v", targets)
+		}
+	})
+
+	t.Run("NotSeekableByTrack", func(t *testing.T) {
+		var targets []string
+		zp := NewMockZonePlayer(t, seekHandlers("Set, Stop, Pause, Play, X_DLNA_SeekTime", "0:01:00", "0:03:25", &targets))
+
+		err := zp.SeekTrack(ctx, 2)
+		var notSeekable *NotSeekableError
+		if !errors.As(err, &notSeekable) || notSeekable.Unit != "TRACK_NR" {
+			t.Errorf("Expected a NotSeekableError, got %v", err)
+		}
+		if len(targets) != 0 {
+			t.Errorf("Expected no seek, got %v", targets)
+		}
+	})
+
+	t.Run("InvalidTrack", func(t *testing.T) {
+		var targets []string
+		zp := NewMockZonePlayer(t, seekHandlers(actions, "0:01:00", "0:03:25", &targets))
+		if err := zp.SeekTrack(ctx, 0); err == nil {
+			t.Error("Expected an error for track 0")
+		}
+	})
+}
@@ -83,6 +83,59 @@ const (
 // State Variables
 type LastChange string
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"TransportState":               "string",
+	"TransportStatus":              "string",
+	"TransportErrorDescription":    "string",
+	"TransportErrorURI":            "string",
+	"TransportErrorHttpCode":       "string",
+	"TransportErrorHttpHeaders":    "string",
+	"PlaybackStorageMedium":        "string",
+	"RecordStorageMedium":          "string",
+	"PossiblePlaybackStorageMedia": "string",
+	"PossibleRecordStorageMedia":   "string",
+	"CurrentPlayMode":              "string",
+	"CurrentCrossfadeMode":         "boolean",
+	"TransportPlaySpeed":           "string",
+	"RecordMediumWriteStatus":      "string",
+	"CurrentRecordQualityMode":     "string",
+	"PossibleRecordQualityModes":   "string",
+	"NumberOfTracks":               "ui4",
+	"CurrentTrack":                 "ui4",
+	"CurrentSection":               "ui4",
+	"CurrentTrackDuration":         "string",
+	"CurrentMediaDuration":         "string",
+	"CurrentTrackMetaData":         "string",
+	"CurrentTrackURI":              "string",
+	"AVTransportURI":               "string",
+	"AVTransportURIMetaData":       "string",
+	"NextAVTransportURI":           "string",
+	"NextAVTransportURIMetaData":   "string",
+	"RelativeTimePosition":         "string",
+	"AbsoluteTimePosition":         "string",
+	"RelativeCounterPosition":      "i4",
+	"AbsoluteCounterPosition":      "i4",
+	"CurrentTransportActions":      "string",
+	"SleepTimerGeneration":         "ui4",
+	"SnoozeRunning":                "boolean",
+	"AlarmRunning":                 "boolean",
+	"AlarmIDRunning":               "ui4",
+	"AlarmLoggedStartTime":         "string",
+	"RestartPending":               "boolean",
+	"LastChange":                   "string",
+	"NextTrackMetaData":            "string",
+	"NextTrackURI":                 "string",
+	"EnqueuedTransportURIMetaData": "string",
+	"EnqueuedTransportURI":         "string",
+	"CurrentValidPlayModes":        "string",
+	"MuseSessions":                 "string",
+	"DirectControlClientID":        "string",
+	"DirectControlAccountID":       "string",
+	"DirectControlIsSuspended":     "boolean",
+	"QueueUpdateID":                "ui4",
+}
+
 // Service represents AVTransport service.
 type Service struct {
 	controlEndpoint *url.URL
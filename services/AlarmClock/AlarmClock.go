@@ -60,6 +60,17 @@ type DailyIndexRefreshTime string
 type TimeFormat string
 type DateFormat string
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"TimeZone":              "string",
+	"TimeServer":            "string",
+	"TimeGeneration":        "ui4",
+	"AlarmListVersion":      "string",
+	"DailyIndexRefreshTime": "string",
+	"TimeFormat":            "string",
+	"DateFormat":            "string",
+}
+
 // Service represents AlarmClock service.
 type Service struct {
 	controlEndpoint       *url.URL
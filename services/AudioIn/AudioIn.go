@@ -42,6 +42,16 @@ type LeftLineInLevel int32
 type RightLineInLevel int32
 type Playing bool
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"AudioInputName":   "string",
+	"Icon":             "string",
+	"LineInConnected":  "boolean",
+	"LeftLineInLevel":  "i4",
+	"RightLineInLevel": "i4",
+	"Playing":          "boolean",
+}
+
 // Service represents AudioIn service.
 type Service struct {
 	controlEndpoint  *url.URL
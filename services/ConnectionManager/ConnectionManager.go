@@ -55,6 +55,13 @@ type SourceProtocolInfo string
 type SinkProtocolInfo string
 type CurrentConnectionIDs string
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"SourceProtocolInfo":   "string",
+	"SinkProtocolInfo":     "string",
+	"CurrentConnectionIDs": "string",
+}
+
 // Service represents ConnectionManager service.
 type Service struct {
 	controlEndpoint      *url.URL
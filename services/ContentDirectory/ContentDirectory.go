@@ -55,6 +55,25 @@ type RadioLocationUpdateID uint32
 type FavoritesUpdateID string
 type FavoritePresetsUpdateID string
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"SearchCapabilities":      "string",
+	"SortCapabilities":        "string",
+	"SystemUpdateID":          "ui4",
+	"ContainerUpdateIDs":      "string",
+	"ShareIndexInProgress":    "boolean",
+	"ShareIndexLastError":     "string",
+	"UserRadioUpdateID":       "string",
+	"SavedQueuesUpdateID":     "string",
+	"ShareListUpdateID":       "string",
+	"RecentlyPlayedUpdateID":  "string",
+	"Browseable":              "boolean",
+	"RadioFavoritesUpdateID":  "ui4",
+	"RadioLocationUpdateID":   "ui4",
+	"FavoritesUpdateID":       "string",
+	"FavoritePresetsUpdateID": "string",
+}
+
 // Service represents ContentDirectory service.
 type Service struct {
 	controlEndpoint         *url.URL
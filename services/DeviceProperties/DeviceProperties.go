@@ -80,6 +80,62 @@ type SecureRegState uint32
 type VoiceConfigState uint32
 type MicEnabled uint32
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"HouseholdID":                "string",
+	"SettingsReplicationState":   "string",
+	"ZoneName":                   "string",
+	"Icon":                       "string",
+	"Configuration":              "string",
+	"TargetRoomName":             "string",
+	"Invisible":                  "boolean",
+	"IsZoneBridge":               "boolean",
+	"AirPlayEnabled":             "boolean",
+	"SupportsAudioIn":            "boolean",
+	"SupportsAudioClip":          "boolean",
+	"IsIdle":                     "boolean",
+	"MoreInfo":                   "string",
+	"ChannelMapSet":              "string",
+	"HTSatChanMapSet":            "string",
+	"HTBondedZoneCommitState":    "ui4",
+	"Orientation":                "i4",
+	"LastChangedPlayState":       "string",
+	"RoomCalibrationState":       "i4",
+	"AvailableRoomCalibration":   "string",
+	"SatRoomUUID":                "string",
+	"LEDState":                   "string",
+	"SerialNumber":               "string",
+	"SoftwareVersion":            "string",
+	"DisplaySoftwareVersion":     "string",
+	"HardwareVersion":            "string",
+	"IPAddress":                  "string",
+	"MACAddress":                 "string",
+	"CopyrightInfo":              "string",
+	"ExtraInfo":                  "string",
+	"HTAudioIn":                  "ui4",
+	"Flags":                      "ui4",
+	"AutoplayIncludeLinkedZones": "boolean",
+	"AutoplayRoomUUID":           "string",
+	"AutoplaySource":             "string",
+	"AutoplayVolume":             "ui2",
+	"AutoplayUseVolume":          "boolean",
+	"TVConfigurationError":       "boolean",
+	"HdmiCecAvailable":           "boolean",
+	"WirelessMode":               "ui4",
+	"WirelessLeafOnly":           "boolean",
+	"HasConfiguredSSID":          "boolean",
+	"ChannelFreq":                "ui4",
+	"BehindWifiExtender":         "ui4",
+	"WifiEnabled":                "boolean",
+	"EthLink":                    "boolean",
+	"ConfigMode":                 "string",
+	"SecureRegState":             "ui4",
+	"ButtonLockState":            "string",
+	"VoiceConfigState":           "ui4",
+	"MicEnabled":                 "ui4",
+	"KeepGrouped":                "boolean",
+}
+
 // Service represents DeviceProperties service.
 type Service struct {
 	controlEndpoint          *url.URL
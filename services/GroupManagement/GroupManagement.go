@@ -41,6 +41,16 @@ type VirtualLineInGroupID string
 type ResetVolumeAfter bool
 type VolumeAVTransportURI string
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"GroupCoordinatorIsLocal": "boolean",
+	"LocalGroupUUID":          "string",
+	"VirtualLineInGroupID":    "string",
+	"SourceAreaIds":           "string",
+	"ResetVolumeAfter":        "boolean",
+	"VolumeAVTransportURI":    "string",
+}
+
 // Service represents GroupManagement service.
 type Service struct {
 	controlEndpoint         *url.URL
@@ -39,6 +39,13 @@ type GroupMute bool
 type GroupVolume uint16
 type GroupVolumeChangeable bool
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"GroupMute":             "boolean",
+	"GroupVolume":           "ui2",
+	"GroupVolumeChangeable": "boolean",
+}
+
 // Service represents GroupRenderingControl service.
 type Service struct {
 	controlEndpoint       *url.URL
@@ -37,6 +37,14 @@ func WithLocation(u *url.URL) ServiceOption {
 // State Variables
 type ServiceListVersion string
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"ServiceId":          "ui4",
+	"ServiceListVersion": "string",
+	"SessionId":          "string",
+	"Username":           "string",
+}
+
 // Service represents MusicServices service.
 type Service struct {
 	controlEndpoint    *url.URL
@@ -36,6 +36,9 @@ func WithLocation(u *url.URL) ServiceOption {
 
 // State Variables
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{}
+
 // Service represents QPlay service.
 type Service struct {
 	controlEndpoint *url.URL
@@ -37,6 +37,13 @@ func WithLocation(u *url.URL) ServiceOption {
 // State Variables
 type LastChange string
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"LastChange": "string",
+	"UpdateID":   "ui4",
+	"Curated":    "boolean",
+}
+
 // Service represents Queue service.
 type Service struct {
 	controlEndpoint *url.URL
@@ -60,6 +60,43 @@ const (
 // State Variables
 type LastChange string
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"LastChange":                     "string",
+	"Mute":                           "boolean",
+	"Volume":                         "ui2",
+	"VolumeDB":                       "i2",
+	"Bass":                           "i2",
+	"Treble":                         "i2",
+	"EQValue":                        "i2",
+	"Loudness":                       "boolean",
+	"SupportsOutputFixed":            "boolean",
+	"OutputFixed":                    "boolean",
+	"HeadphoneConnected":             "boolean",
+	"AudioDelay":                     "string",
+	"AudioDelayLeftRear":             "string",
+	"AudioDelayRightRear":            "string",
+	"DialogLevel":                    "string",
+	"SupportsMaxDialogLevel":         "boolean",
+	"SpeechEnhanceEnabled":           "boolean",
+	"SpeakerSize":                    "ui4",
+	"SubCrossover":                   "string",
+	"SubEnabled":                     "boolean",
+	"SubGain":                        "string",
+	"SubPolarity":                    "string",
+	"SurroundLevel":                  "string",
+	"MusicSurroundLevel":             "string",
+	"NightMode":                      "boolean",
+	"SurroundEnabled":                "boolean",
+	"SurroundMode":                   "string",
+	"PresetNameList":                 "string",
+	"RoomCalibrationID":              "string",
+	"RoomCalibrationCoefficients":    "string",
+	"RoomCalibrationCalibrationMode": "string",
+	"RoomCalibrationEnabled":         "boolean",
+	"RoomCalibrationAvailable":       "boolean",
+}
+
 // Service represents RenderingControl service.
 type Service struct {
 	controlEndpoint *url.URL
@@ -41,6 +41,15 @@ type UpdateIDX uint32
 type VoiceUpdateID uint32
 type ThirdPartyHash string
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"CustomerID":     "string",
+	"UpdateID":       "ui4",
+	"UpdateIDX":      "ui4",
+	"VoiceUpdateID":  "ui4",
+	"ThirdPartyHash": "string",
+}
+
 // Service represents SystemProperties service.
 type Service struct {
 	controlEndpoint *url.URL
@@ -37,6 +37,14 @@ func WithLocation(u *url.URL) ServiceOption {
 // State Variables
 type CurrentTrackMetaData string
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"CurrentTrackMetaData":         "string",
+	"EnqueuedTransportURIMetaData": "string",
+	"AVTransportURIMetaData":       "string",
+	"CurrentTransportActions":      "string",
+}
+
 // Service represents VirtualLineIn service.
 type Service struct {
 	controlEndpoint      *url.URL
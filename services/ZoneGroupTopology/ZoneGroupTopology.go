@@ -61,6 +61,22 @@ type AreasUpdateID string
 type SourceAreasUpdateID string
 type NetsettingsUpdateID string
 
+// StateVariableTypes maps the state variables of the service to their SCPD data types.
+var StateVariableTypes = map[string]string{
+	"AvailableSoftwareUpdate": "string",
+	"ZoneGroupState":          "string",
+	"ThirdPartyMediaServersX": "string",
+	"AlarmRunSequence":        "string",
+	"MuseHouseholdId":         "string",
+	"ZoneGroupName":           "string",
+	"ZoneGroupID":             "string",
+	"ZonePlayerUUIDsInGroup":  "string",
+	"DiagnosticID":            "ui4",
+	"AreasUpdateID":           "string",
+	"SourceAreasUpdateID":     "string",
+	"NetsettingsUpdateID":     "string",
+}
+
 // Service represents ZoneGroupTopology service.
 type Service struct {
 	controlEndpoint         *url.URL
@@ -0,0 +1,127 @@
+package sonos
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+// MaxSleepTimer is the longest sleep timer the players accept.
+const MaxSleepTimer = 24*time.Hour - time.Second
+
+// SleepTimerEvent is delivered by AVTransport subscriptions when the sleep timer was set,
+// changed, cancelled or expired.
+type SleepTimerEvent struct {
+	// Incremented by the player on every change
+	Generation int
+	// Zero once there is no sleep timer
+	Remaining time.Duration
+}
+
+// SetSleepTimer stops playback once d, rounded to seconds, has passed. It replaces the current
+// sleep timer, if any.
+func (z *ZonePlayer) SetSleepTimer(d time.Duration) error {
+	d = d.Round(time.Second)
+	if d <= 0 || d > MaxSleepTimer {
+		return fmt.Errorf("sleep timer %s out of range (1s-%s)", d, MaxSleepTimer)
+	}
+	_, err := z.AVTransport.ConfigureSleepTimer(&avt.ConfigureSleepTimerArgs{InstanceID: 0, NewSleepTimerDuration: formatSleepTimer(d)})
+	return err
+}
+
+func (z *ZonePlayer) CancelSleepTimer() error {
+	_, err := z.AVTransport.ConfigureSleepTimer(&avt.ConfigureSleepTimerArgs{InstanceID: 0, NewSleepTimerDuration: ""})
+	return err
+}
+
+// SleepTimerRemaining returns how long the sleep timer has left, zero without one.
+func (z *ZonePlayer) SleepTimerRemaining() (time.Duration, error) {
+	res, err := z.AVTransport.GetRemainingSleepTimerDuration(&avt.GetRemainingSleepTimerDurationArgs{InstanceID: 0})
+	if err != nil {
+		return 0, err
+	}
+	return parseDuration(res.RemainingSleepTimerDuration), nil
+}
+
+// WatchSleepTimer calls fn whenever the sleep timer of the player changes, as reported by the
+// SleepTimerEvents of an AVTransport subscription to it. The returned function stops watching.
+func (z *ZonePlayer) WatchSleepTimer(fn func(SleepTimerEvent)) func() {
+	var mu sync.Mutex
+	generation := -1
+	return z.watch(func(evt interface{}) {
+		e, ok := evt.(SleepTimerEvent)
+		if !ok {
+			return
+		}
+		// Every subscription to the player reports the change
+		mu.Lock()
+		defer mu.Unlock()
+		if e.Generation == generation {
+			return
+		}
+		generation = e.Generation
+		fn(e)
+	})
+}
+
+// sleepTimer is the sleep timer generation last reported for a subscription.
+type sleepTimer struct {
+	mu         sync.Mutex
+	generation int
+}
+
+// emitSleepTimer emits a SleepTimerEvent for the subscription if its sleep timer generation
+// changed. The remaining duration is requested outside of the event delivery.
+func (s *Sonos) emitSleepTimer(opts *SubscriptionOptions, sid string) {
+	if _, ok := s.subscriptions.Load(sid); !ok {
+		return
+	}
+	v, _ := s.sleepTimers.LoadOrStore(sid, &sleepTimer{generation: -1})
+	st := v.(*sleepTimer)
+
+	go func() {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+
+		zp := opts.ZonePlayer
+		res, err := zp.AVTransport.GetRemainingSleepTimerDuration(&avt.GetRemainingSleepTimerDurationArgs{InstanceID: 0})
+		if err != nil {
+			s.logger.Warn("failed to get the sleep timer", "room", zp.RoomName(), "error", err)
+			return
+		}
+		if int(res.CurrentSleepTimerGeneration) == st.generation {
+			return
+		}
+		st.generation = int(res.CurrentSleepTimerGeneration)
+
+		// The subscription may have ended meanwhile
+		if _, ok := s.subscriptions.Load(sid); !ok {
+			return
+		}
+		s.emit(opts, sid, "", SleepTimerEvent{Generation: st.generation, Remaining: parseDuration(res.RemainingSleepTimerDuration)})
+	}()
+}
+
+// sleepTimerChanged reports whether the event carries the sleep timer generation.
+func sleepTimerChanged(evt interface{}) bool {
+	switch e := evt.(type) {
+	case AVTransportLastChange:
+		return e.InstanceID.present["SleepTimerGeneration"]
+	case LastChangeEvent:
+		if e.Service != "AVTransport" {
+			return false
+		}
+		if i, ok := e.Instance("0"); ok {
+			_, ok := i.Get("SleepTimerGeneration")
+			return ok
+		}
+	}
+	return false
+}
+
+// formatSleepTimer formats the duration as HH:MM:SS, the format ConfigureSleepTimer expects.
+func formatSleepTimer(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
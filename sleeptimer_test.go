@@ -0,0 +1,140 @@
+package sonos
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSleepTimer(t *testing.T) {
+	var sent []string
+	newDuration := regexp.MustCompile(`<NewSleepTimerDuration>(.*)</NewSleepTimerDuration>`)
+	zp := NewMockZonePlayer(t, map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:AVTransport:1#ConfigureSleepTimer": func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			if m := newDuration.FindSubmatch(body); m != nil {
+				sent = append(sent, string(m[1]))
+			}
+			return mockSuccessHandler("AVTransport", "ConfigureSleepTimer")(req)
+		},
+		"urn:schemas-upnp-org:service:AVTransport:1#GetRemainingSleepTimerDuration": mockResponseHandler("AVTransport", "GetRemainingSleepTimerDuration", "<RemainingSleepTimerDuration>0:29:58</RemainingSleepTimerDuration><CurrentSleepTimerGeneration>3</CurrentSleepTimerGeneration>"),
+	})
+
+	if err := zp.SetSleepTimer(30 * time.Minute); err != nil {
+		t.Fatalf("SetSleepTimer failed: %v", err)
+	}
+	if err := zp.SetSleepTimer(90*time.Minute + 400*time.Millisecond); err != nil {
+		t.Fatalf("SetSleepTimer failed: %v", err)
+	}
+	if err := zp.CancelSleepTimer(); err != nil {
+		t.Fatalf("CancelSleepTimer failed: %v", err)
+	}
+	if want := []string{"00:30:00", "01:30:00", ""}; len(sent) != len(want) || sent[0] != want[0] || sent[1] != want[1] || sent[2] != want[2] {
+		t.Errorf("Expected %q, got %q", want, sent)
+	}
+
+	for _, d := range []time.Duration{0, -time.Minute, 24 * time.Hour} {
+		if err := zp.SetSleepTimer(d); err == nil {
+			t.Errorf("Expected an error for %s", d)
+		}
+	}
+
+	remaining, err := zp.SleepTimerRemaining()
+	if err != nil || remaining != 29*time.Minute+58*time.Second {
+		t.Errorf("Expected 29m58s, got %s (%v)", remaining, err)
+	}
+}
+
+func TestWatchSleepTimer(t *testing.T) {
+	var generation atomic.Int32
+	device := newFakeDevice(t, map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:AVTransport:1#GetRemainingSleepTimerDuration": func(req *http.Request) (*http.Response, error) {
+			remaining := ""
+			if generation.Load() == 1 {
+				remaining = "0:30:00"
+			}
+			return mockResponseHandler("AVTransport", "GetRemainingSleepTimerDuration", "<RemainingSleepTimerDuration>"+remaining+"</RemainingSleepTimerDuration><CurrentSleepTimerGeneration>"+strconv.Itoa(int(generation.Load()))+"</CurrentSleepTimerGeneration>")(req)
+		},
+	})
+	zp := device.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	handled := make(chan SleepTimerEvent, 10)
+	sid, err := s.Subscribe(ctx, &SubscriptionOptions{ZonePlayer: zp, Service: zp.AVTransport, EventHandler: func(evt interface{}) {
+		if e, ok := evt.(SleepTimerEvent); ok {
+			handled <- e
+		}
+	}})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	events := make(chan SleepTimerEvent, 10)
+	stop := zp.WatchSleepTimer(func(e SleepTimerEvent) {
+		events <- e
+	})
+	defer stop()
+
+	notify := func(event string) {
+		t.Helper()
+		if status, err := device.Notify(sid, lastChangeEvent(event)); err != nil || status != http.StatusOK {
+			t.Fatalf("Notify failed: %d %v", status, err)
+		}
+	}
+	next := func() SleepTimerEvent {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-ctx.Done():
+			t.Fatal("No sleep timer event")
+			return SleepTimerEvent{}
+		}
+	}
+
+	// Unrelated changes don't count
+	notify(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/></InstanceID></Event>`)
+
+	generation.Store(1)
+	notify(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><SleepTimerGeneration val="1"/></InstanceID></Event>`)
+	if e := next(); e.Generation != 1 || e.Remaining != 30*time.Minute {
+		t.Errorf("Expected the timer to be set, got %+v", e)
+	}
+
+	generation.Store(2)
+	notify(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><SleepTimerGeneration val="2"/></InstanceID></Event>`)
+	if e := next(); e.Generation != 2 || e.Remaining != 0 {
+		t.Errorf("Expected the timer to be cancelled, got %+v", e)
+	}
+
+	select {
+	case e := <-events:
+		t.Errorf("Unexpected event %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The handler of the subscription gets them as well, and so would the journal
+	if len(handled) != 2 {
+		t.Fatalf("Expected the handler to get both changes, got %d", len(handled))
+	}
+	e := <-handled
+	entry, err := newJournalEntry(&SubscriptionOptions{ZonePlayer: zp, Service: zp.AVTransport}, sid, "", e)
+	if err != nil {
+		t.Fatalf("newJournalEntry failed: %v", err)
+	}
+	if evt, err := entry.Event(); err != nil || evt != e {
+		t.Errorf("Expected %+v from the journal, got %+v (%v)", e, evt, err)
+	}
+}
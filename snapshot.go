@@ -0,0 +1,362 @@
+package sonos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+	rcg "github.com/caglar10ur/sonos/services/GroupRenderingControl"
+	ren "github.com/caglar10ur/sonos/services/RenderingControl"
+)
+
+// Snapshot is a point in time capture of a household: how the rooms are grouped,
+// what every group is playing and how loud every room is. It is safe to
+// serialize as JSON and restore later, e.g. after an announcement.
+type Snapshot struct {
+	Time   time.Time       `json:"time"`
+	Groups []GroupSnapshot `json:"groups"`
+}
+
+// GroupSnapshot holds the state of a single zone group.
+type GroupSnapshot struct {
+	ID          string            `json:"id"`
+	Coordinator string            `json:"coordinator"`
+	Members     []MemberSnapshot  `json:"members"`
+	Transport   TransportSnapshot `json:"transport"`
+	// Sonos derives the group volume and mute from the members, they are captured for
+	// reference and restored implicitly by restoring the member volumes and mutes.
+	Volume int  `json:"volume"`
+	Mute   bool `json:"mute"`
+}
+
+// MemberSnapshot holds the state of a single room within a group.
+type MemberSnapshot struct {
+	UUID     string `json:"uuid"`
+	RoomName string `json:"room_name"`
+	Location string `json:"location"`
+	Volume   int    `json:"volume"`
+	Mute     bool   `json:"mute"`
+}
+
+// TransportSnapshot holds the AVTransport state of a group coordinator.
+type TransportSnapshot struct {
+	URI       string                  `json:"uri"`
+	MetaData  string                  `json:"metadata"`
+	Track     uint32                  `json:"track"`
+	RelTime   string                  `json:"rel_time"`
+	PlayMode  avt.CurrentPlayModeEnum `json:"play_mode"`
+	Crossfade bool                    `json:"crossfade"`
+	State     avt.TransportStateEnum  `json:"state"`
+}
+
+// IsQueue reports whether the transport was playing from the queue.
+func (t *TransportSnapshot) IsQueue() bool {
+	return strings.HasPrefix(t.URI, "x-rincon-queue:")
+}
+
+// Snapshot captures the state of every visible group in the household the ZonePlayer belongs to.
+func (z *ZonePlayer) Snapshot() (*Snapshot, error) {
+	return z.snapshot(func(ZoneGroup) bool { return true })
+}
+
+// snapshot captures the state of the groups include returns true for.
+func (z *ZonePlayer) snapshot(include func(ZoneGroup) bool) (*Snapshot, error) {
+	zoneGroupState, err := z.GetZoneGroupState()
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{Time: time.Now()}
+	for _, group := range zoneGroupState.ZoneGroups {
+		if !include(group) {
+			continue
+		}
+		gs := GroupSnapshot{
+			ID:          group.ID,
+			Coordinator: group.Coordinator,
+		}
+
+		var coordinator *ZonePlayer
+		for _, member := range group.VisibleMembers() {
+			zp, err := z.zonePlayerAt(member.UUID, member.Location)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", member.ZoneName, err)
+			}
+
+			ms := MemberSnapshot{
+				UUID:     member.UUID,
+				RoomName: member.ZoneName,
+				Location: member.Location,
+			}
+			if ms.Volume, err = zp.GetVolume(); err != nil {
+				return nil, fmt.Errorf("%s: %w", member.ZoneName, err)
+			}
+			if ms.Mute, err = zp.IsMuted(); err != nil {
+				return nil, fmt.Errorf("%s: %w", member.ZoneName, err)
+			}
+			gs.Members = append(gs.Members, ms)
+
+			if member.UUID == group.Coordinator {
+				coordinator = zp
+			}
+		}
+
+		// Groups consisting of invisible players only (e.g. a Boost) have nothing to capture
+		if coordinator == nil {
+			continue
+		}
+
+		if err := coordinator.snapshotGroup(&gs); err != nil {
+			return nil, fmt.Errorf("%s: %w", coordinator.RoomName(), err)
+		}
+		snap.Groups = append(snap.Groups, gs)
+	}
+
+	return snap, nil
+}
+
+func (z *ZonePlayer) snapshotGroup(gs *GroupSnapshot) error {
+	volume, err := z.GetGroupVolume()
+	if err != nil {
+		return err
+	}
+	gs.Volume = volume
+
+	mute, err := z.GroupRenderingControl.GetGroupMute(&rcg.GetGroupMuteArgs{InstanceID: 0})
+	if err != nil {
+		return err
+	}
+	gs.Mute = mute.CurrentMute
+
+	media, err := z.AVTransport.GetMediaInfo(&avt.GetMediaInfoArgs{InstanceID: 0})
+	if err != nil {
+		return err
+	}
+	gs.Transport.URI = media.CurrentURI
+	gs.Transport.MetaData = media.CurrentURIMetaData
+
+	position, err := z.GetPositionInfo()
+	if err != nil {
+		return err
+	}
+	gs.Transport.Track = position.Track
+	gs.Transport.RelTime = position.RelTime
+
+	settings, err := z.AVTransport.GetTransportSettings(&avt.GetTransportSettingsArgs{InstanceID: 0})
+	if err != nil {
+		return err
+	}
+	gs.Transport.PlayMode = settings.PlayMode
+
+	crossfade, err := z.AVTransport.GetCrossfadeMode(&avt.GetCrossfadeModeArgs{InstanceID: 0})
+	if err != nil {
+		return err
+	}
+	gs.Transport.Crossfade = crossfade.CrossfadeMode
+
+	info, err := z.AVTransport.GetTransportInfo(&avt.GetTransportInfoArgs{InstanceID: 0})
+	if err != nil {
+		return err
+	}
+	gs.Transport.State = info.CurrentTransportState
+
+	return nil
+}
+
+// Restore puts the household back into the captured state. Grouping is restored first,
+// followed by the transport of every group coordinator and lastly the volume and mute state.
+// Restore keeps going when a single room fails and returns all the errors it encountered.
+//
+// The given options are passed to NewZonePlayer when connecting to the captured rooms.
+func (s *Snapshot) Restore(ctx context.Context, opts ...ZonePlayerOption) error {
+	players := make(map[string]*ZonePlayer)
+	for _, group := range s.Groups {
+		for _, member := range group.Members {
+			u, err := FromLocation(member.Location)
+			if err != nil {
+				return fmt.Errorf("%s: %w", member.RoomName, err)
+			}
+			zp, err := NewZonePlayer(append([]ZonePlayerOption{WithLocation(u)}, opts...)...)
+			if err != nil {
+				return fmt.Errorf("%s: %w", member.RoomName, err)
+			}
+			players[member.UUID] = zp
+		}
+	}
+
+	var errs []error
+	if err := s.restoreGrouping(ctx, players); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, group := range s.Groups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		coordinator, ok := players[group.Coordinator]
+		if !ok {
+			continue
+		}
+		if err := coordinator.restoreTransport(&group.Transport); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", coordinator.RoomName(), err))
+		}
+
+		for _, member := range group.Members {
+			if err := players[member.UUID].restoreVolume(&member); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", member.RoomName, err))
+			}
+		}
+
+		if err := coordinator.restoreState(group.Transport.State); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", coordinator.RoomName(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Snapshot) restoreGrouping(ctx context.Context, players map[string]*ZonePlayer) error {
+	var observer *ZonePlayer
+	for _, zp := range players {
+		observer = zp
+		break
+	}
+	if observer == nil {
+		return nil
+	}
+
+	// current maps the players to their current coordinators
+	topology := func() (map[string]string, error) {
+		zoneGroupState, err := observer.GetZoneGroupState()
+		if err != nil {
+			return nil, err
+		}
+		current := make(map[string]string)
+		for _, group := range zoneGroupState.ZoneGroups {
+			for _, member := range group.ZoneGroupMember {
+				current[member.UUID] = group.Coordinator
+			}
+		}
+		return current, nil
+	}
+	current, err := topology()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	// Coordinators have to leave their current groups before anyone can join them
+	var left bool
+	for _, group := range s.Groups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		zp, ok := players[group.Coordinator]
+		if !ok || current[group.Coordinator] == group.Coordinator {
+			continue
+		}
+		if _, err := zp.AVTransport.BecomeCoordinatorOfStandaloneGroup(&avt.BecomeCoordinatorOfStandaloneGroupArgs{InstanceID: 0}); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", zp.RoomName(), err))
+			continue
+		}
+		left = true
+	}
+	// The groups left behind got new coordinators
+	if left {
+		if current, err = topology(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+	}
+
+	for _, group := range s.Groups {
+		for _, member := range group.Members {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if member.UUID == group.Coordinator || current[member.UUID] == group.Coordinator {
+				continue
+			}
+			if err := players[member.UUID].SetAVTransportURI(fmt.Sprintf("x-rincon:%s", group.Coordinator)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", member.RoomName, err))
+				continue
+			}
+			current[member.UUID] = group.Coordinator
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (z *ZonePlayer) restoreVolume(m *MemberSnapshot) error {
+	err := z.SetVolume(m.Volume)
+	if _, muteErr := z.RenderingControl.SetMute(&ren.SetMuteArgs{InstanceID: 0, Channel: "Master", DesiredMute: m.Mute}); muteErr != nil {
+		err = errors.Join(err, muteErr)
+	}
+	return err
+}
+
+func (z *ZonePlayer) restoreTransport(t *TransportSnapshot) error {
+	if t.URI == "" {
+		return nil
+	}
+
+	_, err := z.AVTransport.SetAVTransportURI(&avt.SetAVTransportURIArgs{
+		InstanceID:         0,
+		CurrentURI:         t.URI,
+		CurrentURIMetaData: t.MetaData,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Play mode, crossfade and seeking only apply to the queue
+	if !t.IsQueue() {
+		return nil
+	}
+
+	if t.PlayMode != "" {
+		if _, err := z.AVTransport.SetPlayMode(&avt.SetPlayModeArgs{InstanceID: 0, NewPlayMode: t.PlayMode}); err != nil {
+			return err
+		}
+	}
+	if _, err := z.AVTransport.SetCrossfadeMode(&avt.SetCrossfadeModeArgs{InstanceID: 0, CrossfadeMode: t.Crossfade}); err != nil {
+		return err
+	}
+
+	if t.Track > 0 {
+		if _, err := z.AVTransport.Seek(&avt.SeekArgs{InstanceID: 0, Unit: avt.SeekMode_TRACK_NR, Target: fmt.Sprintf("%d", t.Track)}); err != nil {
+			return err
+		}
+	}
+	if t.RelTime != "" && t.RelTime != "0:00:00" && t.RelTime != "NOT_IMPLEMENTED" {
+		if _, err := z.AVTransport.Seek(&avt.SeekArgs{InstanceID: 0, Unit: avt.SeekMode_REL_TIME, Target: t.RelTime}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (z *ZonePlayer) restoreState(state avt.TransportStateEnum) error {
+	if state == avt.TransportState_PLAYING {
+		return z.Play()
+	}
+
+	info, err := z.AVTransport.GetTransportInfo(&avt.GetTransportInfoArgs{InstanceID: 0})
+	if err != nil {
+		return err
+	}
+	switch info.CurrentTransportState {
+	case avt.TransportState_PLAYING, avt.TransportState_TRANSITIONING:
+		// Not every source can be paused (e.g. line-in), fall back to stop
+		if err := z.Pause(); err != nil {
+			return z.Stop()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package sonos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	innerXML := `&lt;ZoneGroupState&gt;&lt;ZoneGroups&gt;&lt;ZoneGroup Coordinator=&quot;RINCON_000E58CDCA4001400&quot; ID=&quot;RINCON_000E58CDCA4001400:1&quot;&gt;&lt;ZoneGroupMember UUID=&quot;RINCON_000E58CDCA4001400&quot; Location=&quot;http://192.168.1.100:1400/xml/device_description.xml&quot; ZoneName=&quot;Kitchen&quot; /&gt;&lt;/ZoneGroup&gt;&lt;/ZoneGroups&gt;&lt;/ZoneGroupState&gt;`
+
+	var calls []string
+	record := func(service, action string) func(*http.Request) (*http.Response, error) {
+		return func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			calls = append(calls, action+" "+string(body))
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(soapEnvelope(service, action, ""))),
+				Header:     make(http.Header),
+			}, nil
+		}
+	}
+
+	handlers := map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:ZoneGroupTopology:1#GetZoneGroupState":  mockResponseHandler("ZoneGroupTopology", "GetZoneGroupState", "<ZoneGroupState>"+innerXML+"</ZoneGroupState>"),
+		"urn:schemas-upnp-org:service:RenderingControl:1#GetVolume":           mockResponseHandler("RenderingControl", "GetVolume", "<CurrentVolume>17</CurrentVolume>"),
+		"urn:schemas-upnp-org:service:RenderingControl:1#GetMute":             mockResponseHandler("RenderingControl", "GetMute", "<CurrentMute>1</CurrentMute>"),
+		"urn:schemas-upnp-org:service:GroupRenderingControl:1#GetGroupVolume": mockResponseHandler("GroupRenderingControl", "GetGroupVolume", "<CurrentVolume>17</CurrentVolume>"),
+		"urn:schemas-upnp-org:service:GroupRenderingControl:1#GetGroupMute":   mockResponseHandler("GroupRenderingControl", "GetGroupMute", "<CurrentMute>0</CurrentMute>"),
+		"urn:schemas-upnp-org:service:AVTransport:1#GetMediaInfo":             mockResponseHandler("AVTransport", "GetMediaInfo", "<NrTracks>12</NrTracks><CurrentURI>x-rincon-queue:RINCON_000E58CDCA4001400#0</CurrentURI><CurrentURIMetaData></CurrentURIMetaData>"),
+		"urn:schemas-upnp-org:service:AVTransport:1#GetPositionInfo":          mockResponseHandler("AVTransport", "GetPositionInfo", "<Track>3</Track><RelTime>0:01:23</RelTime>"),
+		"urn:schemas-upnp-org:service:AVTransport:1#GetTransportSettings":     mockResponseHandler("AVTransport", "GetTransportSettings", "<PlayMode>SHUFFLE</PlayMode>"),
+		"urn:schemas-upnp-org:service:AVTransport:1#GetCrossfadeMode":         mockResponseHandler("AVTransport", "GetCrossfadeMode", "<CrossfadeMode>1</CrossfadeMode>"),
+		"urn:schemas-upnp-org:service:AVTransport:1#GetTransportInfo":         mockResponseHandler("AVTransport", "GetTransportInfo", "<CurrentTransportState>PLAYING</CurrentTransportState>"),
+		"urn:schemas-upnp-org:service:AVTransport:1#SetAVTransportURI":        record("AVTransport", "SetAVTransportURI"),
+		"urn:schemas-upnp-org:service:AVTransport:1#SetPlayMode":              record("AVTransport", "SetPlayMode"),
+		"urn:schemas-upnp-org:service:AVTransport:1#SetCrossfadeMode":         record("AVTransport", "SetCrossfadeMode"),
+		"urn:schemas-upnp-org:service:AVTransport:1#Seek":                     record("AVTransport", "Seek"),
+		"urn:schemas-upnp-org:service:AVTransport:1#Play":                     record("AVTransport", "Play"),
+		"urn:schemas-upnp-org:service:RenderingControl:1#SetVolume":           record("RenderingControl", "SetVolume"),
+		"urn:schemas-upnp-org:service:RenderingControl:1#SetMute":             record("RenderingControl", "SetMute"),
+		"urn:schemas-upnp-org:service:GroupRenderingControl:1#SetGroupMute":   record("GroupRenderingControl", "SetGroupMute"),
+	}
+
+	zp := NewMockZonePlayer(t, handlers)
+
+	snap, err := zp.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var restored Snapshot
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if len(restored.Groups) != 1 || len(restored.Groups[0].Members) != 1 {
+		t.Fatalf("Unexpected snapshot: %+v", restored)
+	}
+	g := restored.Groups[0]
+	if g.Members[0].Volume != 17 || !g.Members[0].Mute {
+		t.Errorf("Unexpected member state: %+v", g.Members[0])
+	}
+	if g.Transport.Track != 3 || g.Transport.RelTime != "0:01:23" || g.Transport.PlayMode != avt.CurrentPlayMode_SHUFFLE || !g.Transport.Crossfade || g.Transport.State != avt.TransportState_PLAYING {
+		t.Errorf("Unexpected transport state: %+v", g.Transport)
+	}
+
+	if err := restored.Restore(context.Background(), WithClient(zp.Client())); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	expected := []string{
+		"SetAVTransportURI <CurrentURI>x-rincon-queue:RINCON_000E58CDCA4001400#0</CurrentURI>",
+		"SetPlayMode <NewPlayMode>SHUFFLE</NewPlayMode>",
+		"SetCrossfadeMode <CrossfadeMode>true</CrossfadeMode>",
+		"Seek <Unit>TRACK_NR</Unit><Target>3</Target>",
+		"Seek <Unit>REL_TIME</Unit><Target>0:01:23</Target>",
+		"SetVolume <DesiredVolume>17</DesiredVolume>",
+		"SetMute <DesiredMute>true</DesiredMute>",
+		"Play ",
+	}
+	if len(calls) != len(expected) {
+		t.Fatalf("Expected %d calls, got %d: %v", len(expected), len(calls), calls)
+	}
+	for i, e := range expected {
+		action, fragment, _ := strings.Cut(e, " ")
+		if !strings.HasPrefix(calls[i], action+" ") || !strings.Contains(calls[i], fragment) {
+			t.Errorf("Call %d: expected %s containing %q, got %s", i, action, fragment, calls[i])
+		}
+	}
+}
@@ -3,16 +3,27 @@ package sonos
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Largest event notification body accepted
+const maxEventSize = 4 << 20
+
+// ErrSubscriptionLost is returned when the device no longer knows a subscription, e.g. after a reboot.
+var ErrSubscriptionLost = errors.New("subscription lost")
+
 type FoundZonePlayerFunc func(*Sonos, *ZonePlayer)
 type EventHandlerFunc func(interface{})
 
@@ -20,18 +31,68 @@ type Sonos struct {
 	udpListener *net.UDPConn
 	tcpListener net.Listener
 
+	// address the event listener binds to
+	listenAddress string
+	// host:port devices send events to
+	advertisedAddress string
+	// path prefix of the callback URLs
+	callbackPrefix string
+	// whether events are served by a server of the caller
+	external bool
+
+	logger *slog.Logger
+	// records the decoded events if set
+	journal *journal
+	// forwards the decoded events to webhooks if set
+	forwarder *Forwarder
+	// held by the announcement in progress
+	announcing chan struct{}
+	// closed once s is closed
+	closed    chan struct{}
+	closeOnce sync.Once
+
 	// map of coordinators
 	zonePlayers sync.Map
-	// map of subscription ids to event handler function
+	// map of subscription ids to subscription options
 	subscriptions sync.Map
+	// map of subscription ids to the SEQ tracking of their events
+	sequences sync.Map
+	// map of subscription ids to the sleep timer reported for them
+	sleepTimers sync.Map
+	// set of the SubscriptionManagers subscribing through s
+	managers sync.Map
+	// map of the subscriptions being resynced to the SID of a gap found meanwhile, if any
+	resyncMu sync.Mutex
+	resyncs  map[*SubscriptionOptions]string
+	// map of callback tokens to subscriptions waiting for their SID
+	pending    sync.Map
+	pendingSeq atomic.Uint64
+}
+
+// pendingSubscription buffers the events that arrive before Subscribe learns the SID.
+type pendingSubscription struct {
+	opts *SubscriptionOptions
+	// held while the buffered events are delivered to keep them ahead of the later ones
+	mu            sync.Mutex
+	notifications []notification
+}
+
+type notification struct {
+	sid  string
+	seq  string
+	data []byte
 }
 
 type SubscriptionOptions struct {
 	ZonePlayer *ZonePlayer
 	Service    SonosService
-	Timeout    uint64
+	// Requested duration in seconds, updated with the duration granted by the device
+	Timeout uint64
 
 	EventHandler EventHandlerFunc
+	// Deliver LastChange events as LastChangeEvent instead of the per service types, which
+	// only keep the first InstanceID
+	GenericLastChange bool
 
 	Sid string
 }
@@ -57,23 +118,95 @@ func (o *SubscriptionOptions) SetSid(sid string) {
 	o.Sid = sid
 }
 
-func NewSonos() (*Sonos, error) {
+type SonosOption func(*Sonos)
+
+// WithListenAddress sets the address the event listener binds to, ":0" by default.
+func WithListenAddress(addr string) SonosOption {
+	return func(s *Sonos) {
+		s.listenAddress = addr
+	}
+}
+
+// WithAdvertisedAddress sets the host:port devices send events to. By default it is the local
+// address used to reach the device combined with the port of the event listener.
+func WithAdvertisedAddress(hostport string) SonosOption {
+	return func(s *Sonos) {
+		s.advertisedAddress = hostport
+	}
+}
+
+// WithCallbackPrefix sets the path prefix of the callback URLs, for when Sonos is mounted
+// under a path of another server.
+func WithCallbackPrefix(prefix string) SonosOption {
+	return func(s *Sonos) {
+		s.callbackPrefix = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// WithoutEventListener stops Sonos from serving events itself. It has to be mounted as an
+// http.Handler of another server and WithAdvertisedAddress has to point to that server.
+func WithoutEventListener() SonosOption {
+	return func(s *Sonos) {
+		s.external = true
+	}
+}
+
+// WithLogger sets the logger used to report problems, slog.Default() by default.
+func WithLogger(logger *slog.Logger) SonosOption {
+	return func(s *Sonos) {
+		s.logger = logger
+	}
+}
+
+// WithJournal records every decoded event to w as JSON lines, see Replay.
+func WithJournal(w io.Writer) SonosOption {
+	return func(s *Sonos) {
+		s.journal = &journal{enc: json.NewEncoder(w)}
+	}
+}
+
+// WithForwarder forwards every decoded event to the webhooks of f.
+func WithForwarder(f *Forwarder) SonosOption {
+	return func(s *Sonos) {
+		s.forwarder = f
+	}
+}
+
+func NewSonos(opts ...SonosOption) (*Sonos, error) {
+	s := &Sonos{
+		listenAddress: ":0",
+		logger:        slog.Default(),
+		announcing:    make(chan struct{}, 1),
+		closed:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.external && s.advertisedAddress == "" {
+		return nil, fmt.Errorf("an advertised address is required without an event listener")
+	}
+	if s.forwarder != nil {
+		s.forwarder.start(s.logger)
+	}
+
 	// Create listener for M-SEARCH
 	udpListener, err := net.ListenUDP("udp", &net.UDPAddr{IP: []byte{0, 0, 0, 0}, Port: 0, Zone: ""})
 	if err != nil {
 		return nil, err
 	}
+	s.udpListener = udpListener
+
+	if s.external {
+		return s, nil
+	}
 
 	// create listener for events
-	tcpListener, err := net.Listen("tcp", ":0")
+	tcpListener, err := net.Listen("tcp", s.listenAddress)
 	if err != nil {
+		udpListener.Close()
 		return nil, err
 	}
-
-	s := &Sonos{
-		udpListener: udpListener,
-		tcpListener: tcpListener,
-	}
+	s.tcpListener = tcpListener
 
 	go func() {
 		http.Serve(s.tcpListener, s)
@@ -82,9 +215,27 @@ func NewSonos() (*Sonos, error) {
 	return s, nil
 }
 
+// Close closes the SubscriptionManagers, unsubscribes the remaining subscriptions on a best
+// effort basis and stops the listeners.
 func (s *Sonos) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.managers.Range(func(key, _ any) bool {
+		_ = key.(*SubscriptionManager).Close(ctx)
+		return true
+	})
+	s.subscriptions.Range(func(key, value any) bool {
+		_ = s.Unsubscribe(ctx, value.(*SubscriptionOptions))
+		return true
+	})
+
 	s.udpListener.Close()
-	s.tcpListener.Close()
+	if s.tcpListener != nil {
+		s.tcpListener.Close()
+	}
 }
 
 func (s *Sonos) Search(ctx context.Context, fn FoundZonePlayerFunc) error {
@@ -102,7 +253,7 @@ func (s *Sonos) Search(ctx context.Context, fn FoundZonePlayerFunc) error {
 			if err != nil {
 				continue
 			}
-			zp, err := NewZonePlayer(WithLocation(location))
+			zp, err := NewZonePlayer(WithLocation(location), WithZonePlayerLogger(s.logger))
 			if err != nil {
 				continue
 			}
@@ -162,20 +313,39 @@ func (s *Sonos) FindRoom(ctx context.Context, room string) (*ZonePlayer, error)
 	}
 }
 
-func (s *Sonos) Subscribe(ctx context.Context, opts *SubscriptionOptions) (string, error) {
+// callbackHost returns the host:port the device reaches the event listener at.
+func (s *Sonos) callbackHost(opts *SubscriptionOptions) (string, error) {
+	if s.advertisedAddress != "" {
+		return s.advertisedAddress, nil
+	}
+
 	conn, err := net.Dial("tcp", opts.Service.EventEndpoint().Host)
 	if err != nil {
 		return "", err
 	}
 	defer conn.Close()
 
-	host := fmt.Sprintf("%s:%d", conn.LocalAddr().(*net.TCPAddr).IP.String(), s.tcpListener.Addr().(*net.TCPAddr).Port)
+	return fmt.Sprintf("%s:%d", conn.LocalAddr().(*net.TCPAddr).IP.String(), s.tcpListener.Addr().(*net.TCPAddr).Port), nil
+}
+
+func (s *Sonos) Subscribe(ctx context.Context, opts *SubscriptionOptions) (string, error) {
+	host, err := s.callbackHost(opts)
+	if err != nil {
+		return "", err
+	}
+
+	// The device sends the initial event as soon as it accepts the subscription, possibly
+	// before the SID is known here. The token in the callback ties such events to this call.
+	token := strconv.FormatUint(s.pendingSeq.Add(1), 10)
+	p := &pendingSubscription{opts: opts}
+	s.pending.Store(token, p)
+	defer s.pending.Delete(token)
 
 	calbackUrl := url.URL{
 		Scheme:   "http",
 		Host:     host,
-		RawQuery: "sn=" + opts.ZonePlayer.SerialNumber(),
-		Path:     opts.Service.EventEndpoint().Path,
+		RawQuery: url.Values{"sn": {opts.ZonePlayer.SerialNumber()}, "sub": {token}}.Encode(),
+		Path:     s.callbackPrefix + opts.Service.EventEndpoint().Path,
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "SUBSCRIBE", opts.Service.EventEndpoint().String(), nil)
@@ -203,9 +373,25 @@ func (s *Sonos) Subscribe(ctx context.Context, opts *SubscriptionOptions) (strin
 		return "", errors.New(string(body))
 	}
 	sid := res.Header.Get("sid")
+	opts.SetSid(sid)
+	if timeout, ok := parseTimeout(res.Header.Get("timeout")); ok {
+		opts.Timeout = timeout
+	}
 
-	// Add the sid to the subscriptions
-	s.subscriptions.LoadOrStore(sid, opts.EventHandler)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Add the sid to the subscriptions and deliver what arrived in the meantime
+	s.sequences.Store(sid, &sequence{})
+	if _, loaded := s.subscriptions.LoadOrStore(sid, opts); !loaded {
+		opts.ZonePlayer.trackSubscription(opts.Service, 1)
+	}
+	for _, n := range p.notifications {
+		if n.sid == sid {
+			s.deliver(opts, n.sid, n.seq, n.data)
+		}
+	}
+	p.notifications = nil
 
 	return sid, nil
 }
@@ -233,13 +419,32 @@ func (s *Sonos) Renew(ctx context.Context, opts *SubscriptionOptions) error {
 		return err
 	}
 
+	if res.StatusCode == http.StatusPreconditionFailed {
+		return fmt.Errorf("%w: %s", ErrSubscriptionLost, string(body))
+	}
 	if res.StatusCode != http.StatusOK {
 		return errors.New(string(body))
 	}
+	if timeout, ok := parseTimeout(res.Header.Get("timeout")); ok {
+		opts.Timeout = timeout
+	}
 
 	return nil
 }
 
+// parseTimeout parses the seconds out of a TIMEOUT header such as "Second-86400".
+func parseTimeout(header string) (uint64, bool) {
+	seconds, ok := strings.CutPrefix(header, "Second-")
+	if !ok {
+		return 0, false
+	}
+	timeout, err := strconv.ParseUint(seconds, 10, 64)
+	if err != nil || timeout == 0 {
+		return 0, false
+	}
+	return timeout, true
+}
+
 func (s *Sonos) Unsubscribe(ctx context.Context, opts *SubscriptionOptions) error {
 	req, err := http.NewRequestWithContext(ctx, "UNSUBSCRIBE", opts.Service.EventEndpoint().String(), nil)
 	if err != nil {
@@ -249,6 +454,9 @@ func (s *Sonos) Unsubscribe(ctx context.Context, opts *SubscriptionOptions) erro
 	req.Header.Add("HOST", opts.Service.EventEndpoint().Host)
 	req.Header.Add("SID", opts.Sid)
 
+	// The device stops sending events either way
+	s.forget(opts.Sid)
+
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
@@ -269,80 +477,154 @@ func (s *Sonos) Unsubscribe(ctx context.Context, opts *SubscriptionOptions) erro
 	return nil
 }
 
+// forget stops delivering the events of the subscription.
+func (s *Sonos) forget(sid string) {
+	if v, loaded := s.subscriptions.LoadAndDelete(sid); loaded {
+		opts := v.(*SubscriptionOptions)
+		opts.ZonePlayer.trackSubscription(opts.Service, -1)
+	}
+	s.sequences.Delete(sid)
+	s.sleepTimers.Delete(sid)
+}
+
 func (s *Sonos) ServeHTTP(response http.ResponseWriter, request *http.Request) {
 	defer request.Body.Close()
 
-	query := request.URL.Query()
-	sn, ok := query["sn"]
-	if !ok {
-		response.WriteHeader(http.StatusNotFound)
+	// https://openconnectivity.org/upnp-specs/UPnP-arch-DeviceArchitecture-v2.0-20200417.pdf#page=96
+	if request.Method != "NOTIFY" {
+		s.reject(response, request, http.StatusMethodNotAllowed, "unexpected method")
+		return
+	}
+	nt, nts := request.Header.Get("nt"), request.Header.Get("nts")
+	if nt == "" || nts == "" {
+		s.reject(response, request, http.StatusBadRequest, "missing NT or NTS header")
+		return
+	}
+	if nt != "upnp:event" || nts != "upnp:propchange" {
+		s.reject(response, request, http.StatusPreconditionFailed, "invalid NT or NTS header")
 		return
 	}
 
-	p, ok := s.zonePlayers.Load(sn[0])
-	if !ok {
-		response.WriteHeader(http.StatusNotFound)
+	sid := request.Header.Get("sid")
+	seq := request.Header.Get("seq")
+
+	// Check the subscription and the sender before reading the event. Events of a subscription
+	// whose SID is not known yet are tied to it by the token of the callback.
+	var p *pendingSubscription
+	if v, ok := s.pending.Load(request.URL.Query().Get("sub")); ok {
+		p = v.(*pendingSubscription)
+	}
+	var opts *SubscriptionOptions
+	if v, ok := s.subscriptions.Load(sid); ok {
+		opts = v.(*SubscriptionOptions)
+	} else if p != nil {
+		opts = p.opts
+	} else {
+		// Let the device know the subscription is unknown
+		s.reject(response, request, http.StatusPreconditionFailed, "unknown SID")
+		return
+	}
+	if !fromDevice(request, opts) {
+		s.reject(response, request, http.StatusForbidden, "unexpected remote address")
 		return
 	}
-	zp := p.(*ZonePlayer)
 
-	data, err := io.ReadAll(request.Body)
+	data, err := io.ReadAll(http.MaxBytesReader(response, request.Body, maxEventSize))
 	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			s.reject(response, request, http.StatusRequestEntityTooLarge, "body too large")
+			return
+		}
 		response.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	var events []interface{}
-	switch request.URL.Path {
-	case zp.AlarmClock.EventEndpoint().Path:
-		events = zp.AlarmClock.ParseEvent(data)
-	case zp.AudioIn.EventEndpoint().Path:
-		events = zp.AudioIn.ParseEvent(data)
-	case zp.AVTransport.EventEndpoint().Path:
-		events = zp.AVTransport.ParseEvent(data)
-	case zp.ConnectionManager.EventEndpoint().Path:
-		events = zp.ConnectionManager.ParseEvent(data)
-	case zp.ContentDirectory.EventEndpoint().Path:
-		events = zp.ContentDirectory.ParseEvent(data)
-	case zp.DeviceProperties.EventEndpoint().Path:
-		events = zp.DeviceProperties.ParseEvent(data)
-	case zp.GroupManagement.EventEndpoint().Path:
-		events = zp.GroupManagement.ParseEvent(data)
-	case zp.GroupRenderingControl.EventEndpoint().Path:
-		events = zp.GroupRenderingControl.ParseEvent(data)
-	case zp.MusicServices.EventEndpoint().Path:
-		events = zp.MusicServices.ParseEvent(data)
-	case zp.QPlay.EventEndpoint().Path:
-		events = zp.QPlay.ParseEvent(data)
-	case zp.Queue.EventEndpoint().Path:
-		events = zp.Queue.ParseEvent(data)
-	case zp.RenderingControl.EventEndpoint().Path:
-		events = zp.RenderingControl.ParseEvent(data)
-	case zp.SystemProperties.EventEndpoint().Path:
-		events = zp.SystemProperties.ParseEvent(data)
-	case zp.VirtualLineIn.EventEndpoint().Path:
-		events = zp.VirtualLineIn.ParseEvent(data)
-	case zp.ZoneGroupTopology.EventEndpoint().Path:
-		events = zp.ZoneGroupTopology.ParseEvent(data)
+	// Buffer the events of a subscription whose SID is not known yet, Subscribe delivers them
+	if p != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if _, ok := s.subscriptions.Load(sid); !ok {
+			p.notifications = append(p.notifications, notification{sid: sid, seq: seq, data: data})
+			response.WriteHeader(http.StatusOK)
+			return
+		}
 	}
 
-	sid := request.Header.Get("sid")
-	seq := request.Header.Get("seq")
+	v, ok := s.subscriptions.Load(sid)
+	if !ok {
+		// Unsubscribed while reading the event
+		s.reject(response, request, http.StatusPreconditionFailed, "unknown SID")
+		return
+	}
 
-	// Response to the Subscription request comes before Subscription calls reads the sid
-	// give it a second and try again for the first update, if this won't work well enough a channel to syncronize could work.
-	fn, ok := s.subscriptions.Load(sid)
-	if !ok && seq == "0" {
-		time.Sleep(1 * time.Second)
+	s.deliver(v.(*SubscriptionOptions), sid, seq, data)
+	response.WriteHeader(http.StatusOK)
+}
 
-		fn, ok = s.subscriptions.Load(sid)
-		if !ok {
-			fn = func(evt interface{}) {}
+func (s *Sonos) reject(response http.ResponseWriter, request *http.Request, status int, reason string) {
+	s.logger.Warn("rejected event notification",
+		"reason", reason,
+		"status", status,
+		"method", request.Method,
+		"remote", request.RemoteAddr,
+		"sid", request.Header.Get("sid"),
+	)
+	response.WriteHeader(status)
+}
+
+// fromDevice reports whether the request was sent by the device of the subscription.
+func fromDevice(request *http.Request, opts *SubscriptionOptions) bool {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	remote := net.ParseIP(host)
+	device := net.ParseIP(opts.Service.EventEndpoint().Hostname())
+	if remote == nil || device == nil {
+		return host == opts.Service.EventEndpoint().Hostname()
+	}
+	return remote.Equal(device)
+}
+
+// dispatch parses the event notification and passes the events to the handler of the subscription.
+func (s *Sonos) dispatch(opts *SubscriptionOptions, sid, seq string, data []byte) {
+	zp := opts.ZonePlayer
+	handler := func(evt interface{}) {
+		s.emit(opts, sid, seq, evt)
+	}
+	for _, evt := range opts.Service.ParseEvent(data) {
+		if opts.GenericLastChange && isLastChange(evt) {
+			zp.lastChangeEvent(evt, handler)
+			continue
 		}
+		zp.Event(evt, handler)
 	}
+}
 
-	for _, evt := range events {
-		zp.Event(evt, fn.(EventHandlerFunc))
+// emit records a decoded event of the subscription and passes it to the watchers of the
+// ZonePlayer and the handler of the subscription, followed by a SleepTimerEvent if it changed.
+func (s *Sonos) emit(opts *SubscriptionOptions, sid, seq string, evt interface{}) {
+	if s.journal != nil || s.forwarder != nil {
+		entry, err := newJournalEntry(opts, sid, seq, evt)
+		if err != nil {
+			s.logger.Warn("failed to encode event", "sid", sid, "error", err)
+		}
+		if err == nil && s.journal != nil {
+			if err := s.journal.record(entry); err != nil {
+				s.logger.Warn("failed to record event", "sid", sid, "error", err)
+			}
+		}
+		if err == nil && s.forwarder != nil {
+			s.forwarder.forward(entry)
+		}
+	}
+	opts.ZonePlayer.notifyWatchers(evt)
+	if opts.EventHandler != nil {
+		opts.EventHandler(evt)
+	}
+	if sleepTimerChanged(evt) {
+		s.emitSleepTimer(opts, sid)
 	}
-	response.WriteHeader(http.StatusOK)
 }
@@ -0,0 +1,223 @@
+package sonos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventsBeforeSid(t *testing.T) {
+	device := newFakeDevice(t, nil)
+	device.initialEvent = lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/></InstanceID></Event>`)
+	zp := device.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	var states []string
+	opts := &SubscriptionOptions{
+		ZonePlayer: zp,
+		Service:    zp.AVTransport,
+		EventHandler: func(evt interface{}) {
+			if e, ok := evt.(AVTransportLastChange); ok {
+				states = append(states, e.InstanceID.TransportState.Value)
+			}
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sid, err := s.Subscribe(ctx, opts)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	// The initial event arrived before the SID was known and is delivered by Subscribe
+	if len(states) != 1 || states[0] != "PLAYING" {
+		t.Fatalf("Expected the initial event to be delivered, got %v", states)
+	}
+
+	status, err := device.Notify(sid, lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="STOPPED"/></InstanceID></Event>`))
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Notify failed: %d %v", status, err)
+	}
+	if len(states) != 2 || states[1] != "STOPPED" {
+		t.Errorf("Expected the second event to be delivered, got %v", states)
+	}
+}
+
+func TestExternalEventListener(t *testing.T) {
+	if _, err := NewSonos(WithoutEventListener()); err == nil {
+		t.Error("Expected an error without an advertised address")
+	}
+
+	device := newFakeDevice(t, nil)
+	device.initialEvent = lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/></InstanceID></Event>`)
+	zp := device.ZonePlayer(t)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s, err := NewSonos(WithoutEventListener(), WithAdvertisedAddress(server.Listener.Addr().String()), WithCallbackPrefix("/sonos/"))
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+	mux.Handle("/sonos/", s)
+
+	var states []string
+	opts := &SubscriptionOptions{
+		ZonePlayer: zp,
+		Service:    zp.AVTransport,
+		EventHandler: func(evt interface{}) {
+			if e, ok := evt.(AVTransportLastChange); ok {
+				states = append(states, e.InstanceID.TransportState.Value)
+			}
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := s.Subscribe(ctx, opts); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if len(states) != 1 || states[0] != "PLAYING" {
+		t.Errorf("Expected the event to be delivered through the mounted handler, got %v", states)
+	}
+
+	callback := device.Callback(opts.Sid)
+	if !strings.HasPrefix(callback, server.URL+"/sonos/MediaRenderer/AVTransport/Event") {
+		t.Errorf("Unexpected callback %s", callback)
+	}
+}
+
+func TestNotifyValidation(t *testing.T) {
+	device := newFakeDevice(t, nil)
+	zp := device.ZonePlayer(t)
+
+	var logs bytes.Buffer
+	s, err := NewSonos(WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	var delivered int
+	opts := &SubscriptionOptions{
+		ZonePlayer: zp,
+		Service:    zp.AVTransport,
+		EventHandler: func(evt interface{}) {
+			delivered++
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sid, err := s.Subscribe(ctx, opts)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	body := lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/></InstanceID></Event>`)
+	callback := fmt.Sprintf("http://127.0.0.1:%d%s", s.tcpListener.Addr().(*net.TCPAddr).Port, zp.AVTransport.EventEndpoint().Path)
+
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		body   string
+		// Source address of the request
+		local  string
+		status int
+	}{
+		{"valid", "NOTIFY", map[string]string{"NT": "upnp:event", "NTS": "upnp:propchange", "SID": sid}, body, "127.0.0.1", http.StatusOK},
+		{"method", "POST", map[string]string{"NT": "upnp:event", "NTS": "upnp:propchange", "SID": sid}, body, "127.0.0.1", http.StatusMethodNotAllowed},
+		{"missing NT", "NOTIFY", map[string]string{"NTS": "upnp:propchange", "SID": sid}, body, "127.0.0.1", http.StatusBadRequest},
+		{"invalid NTS", "NOTIFY", map[string]string{"NT": "upnp:event", "NTS": "ssdp:alive", "SID": sid}, body, "127.0.0.1", http.StatusPreconditionFailed},
+		{"unknown SID", "NOTIFY", map[string]string{"NT": "upnp:event", "NTS": "upnp:propchange", "SID": "uuid:RINCON_unknown"}, body, "127.0.0.1", http.StatusPreconditionFailed},
+		{"remote address", "NOTIFY", map[string]string{"NT": "upnp:event", "NTS": "upnp:propchange", "SID": sid}, body, "127.0.0.2", http.StatusForbidden},
+		{"body size", "NOTIFY", map[string]string{"NT": "upnp:event", "NTS": "upnp:propchange", "SID": sid}, strings.Repeat(" ", maxEventSize+1), "127.0.0.1", http.StatusRequestEntityTooLarge},
+		// The subscription and the sender are checked before the body is read
+		{"unknown SID body size", "NOTIFY", map[string]string{"NT": "upnp:event", "NTS": "upnp:propchange", "SID": "uuid:RINCON_unknown"}, strings.Repeat(" ", maxEventSize+1), "127.0.0.1", http.StatusPreconditionFailed},
+		{"remote address body size", "NOTIFY", map[string]string{"NT": "upnp:event", "NTS": "upnp:propchange", "SID": sid}, strings.Repeat(" ", maxEventSize+1), "127.0.0.2", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{
+				Transport: &http.Transport{
+					DialContext: (&net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(tt.local)}}).DialContext,
+				},
+			}
+			req, _ := http.NewRequest(tt.method, callback, strings.NewReader(tt.body))
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.status {
+				t.Errorf("Expected %d, got %d", tt.status, res.StatusCode)
+			}
+		})
+	}
+
+	if delivered != 1 {
+		t.Errorf("Expected only the valid event to be delivered, got %d", delivered)
+	}
+	if strings.Count(logs.String(), "rejected event notification") != len(tests)-1 {
+		t.Errorf("Expected every rejection to be logged, got %s", logs.String())
+	}
+}
+
+func TestGenericLastChange(t *testing.T) {
+	device := newFakeDevice(t, nil)
+	device.initialEvent = lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/></InstanceID><InstanceID val="1"><TransportState val="STOPPED"/></InstanceID></Event>`)
+	zp := device.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	var events []interface{}
+	opts := &SubscriptionOptions{
+		ZonePlayer:        zp,
+		Service:           zp.AVTransport,
+		GenericLastChange: true,
+		EventHandler: func(evt interface{}) {
+			events = append(events, evt)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := s.Subscribe(ctx, opts); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("Expected a single event, got %v", events)
+	}
+	e, ok := events[0].(LastChangeEvent)
+	if !ok || e.Service != "AVTransport" || len(e.Instances) != 2 {
+		t.Fatalf("Expected a LastChangeEvent with both instances, got %#v", events[0])
+	}
+	if v, _ := e.Instances[1].Get("TransportState"); v.Value != "STOPPED" {
+		t.Errorf("Unexpected state of the second instance %#v", v.Value)
+	}
+}
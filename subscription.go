@@ -0,0 +1,351 @@
+package sonos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SubscriptionState describes the health of a managed subscription.
+type SubscriptionState string
+
+const (
+	// The subscription is registered with the device
+	SubscriptionActive SubscriptionState = "ACTIVE"
+	// The subscription failed to renew and is being re-established
+	SubscriptionRetrying SubscriptionState = "RETRYING"
+)
+
+// SubscriptionHealth is a point in time report of a managed subscription.
+type SubscriptionHealth struct {
+	Options *SubscriptionOptions
+	Sid     string
+	State   SubscriptionState
+	// When the device drops the subscription unless it is renewed
+	Expires time.Time
+	// Number of consecutive failed renewal or resubscription attempts
+	Failures  int
+	LastError error
+}
+
+type SubscriptionManagerOption func(*SubscriptionManager)
+
+// WithRenewFraction sets the fraction of the granted timeout after which subscriptions are renewed.
+func WithRenewFraction(f float64) SubscriptionManagerOption {
+	return func(m *SubscriptionManager) {
+		if f > 0 && f < 1 {
+			m.renewFraction = f
+		}
+	}
+}
+
+// WithBackoff sets the bounds of the exponential backoff used to retry failed renewals.
+func WithBackoff(initial, limit time.Duration) SubscriptionManagerOption {
+	return func(m *SubscriptionManager) {
+		m.minBackoff = initial
+		m.maxBackoff = limit
+	}
+}
+
+// SubscriptionManager keeps subscriptions alive by renewing them before they expire and
+// resubscribing when a device has lost them, e.g. after a reboot.
+type SubscriptionManager struct {
+	sonos *Sonos
+
+	renewFraction float64
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	subs map[*SubscriptionOptions]*managedSubscription
+	// held while a subscription moves to another player
+	moveMu sync.Mutex
+}
+
+type managedSubscription struct {
+	opts   *SubscriptionOptions
+	health SubscriptionHealth
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSubscriptionManager returns a SubscriptionManager subscribing through s.
+func NewSubscriptionManager(s *Sonos, opts ...SubscriptionManagerOption) *SubscriptionManager {
+	m := &SubscriptionManager{
+		sonos:         s,
+		renewFraction: 0.5,
+		minBackoff:    time.Second,
+		maxBackoff:    time.Minute,
+		subs:          make(map[*SubscriptionOptions]*managedSubscription),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	s.managers.Store(m, struct{}{})
+	return m
+}
+
+// Subscribe subscribes to the service and keeps the subscription alive until it is unsubscribed
+// or the manager is closed.
+func (m *SubscriptionManager) Subscribe(ctx context.Context, opts *SubscriptionOptions) error {
+	// Reserve the subscription, nil until it is established, for concurrent calls to fail
+	m.mu.Lock()
+	if _, ok := m.subs[opts]; ok {
+		m.mu.Unlock()
+		return fmt.Errorf("already subscribed")
+	}
+	m.subs[opts] = nil
+	m.mu.Unlock()
+
+	release := func() {
+		m.mu.Lock()
+		delete(m.subs, opts)
+		m.mu.Unlock()
+	}
+	if err := opts.Validate(); err != nil {
+		release()
+		return err
+	}
+	sid, err := m.sonos.Subscribe(ctx, opts)
+	if err != nil {
+		release()
+		return err
+	}
+	if m.ctx.Err() != nil {
+		// Closed while subscribing, nothing would maintain it
+		release()
+		_ = m.sonos.Unsubscribe(ctx, opts)
+		return fmt.Errorf("subscription manager closed")
+	}
+	m.start(opts, SubscriptionHealth{
+		Options: opts,
+		Sid:     sid,
+		State:   SubscriptionActive,
+		Expires: time.Now().Add(time.Duration(opts.Timeout) * time.Second),
+	})
+	return nil
+}
+
+// start maintains the subscription from the given health on.
+func (m *SubscriptionManager) start(opts *SubscriptionOptions, health SubscriptionHealth) {
+	subCtx, cancel := context.WithCancel(m.ctx)
+	ms := &managedSubscription{
+		opts:   opts,
+		health: health,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.subs[opts] = ms
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(ms.done)
+		m.maintain(subCtx, ms)
+	}()
+}
+
+// move resubscribes a managed subscription to the counterpart of its service on zp. It reports
+// false if the subscription isn't managed by m.
+func (m *SubscriptionManager) move(ctx context.Context, opts *SubscriptionOptions, zp *ZonePlayer) (bool, error) {
+	return m.restart(ctx, opts, zp)
+}
+
+// resubscribe replaces a managed subscription by a new one, which makes the device send the full
+// state of the service again. It reports false if the subscription isn't managed by m.
+func (m *SubscriptionManager) resubscribe(ctx context.Context, opts *SubscriptionOptions) (bool, error) {
+	return m.restart(ctx, opts, nil)
+}
+
+// restart unsubscribes a managed subscription and subscribes it again, to the counterpart of its
+// service on zp unless zp is nil. The subscription is neither maintained nor delivering events
+// while its options change.
+func (m *SubscriptionManager) restart(ctx context.Context, opts *SubscriptionOptions, zp *ZonePlayer) (bool, error) {
+	m.moveMu.Lock()
+	defer m.moveMu.Unlock()
+
+	m.mu.Lock()
+	ms, ok := m.subs[opts]
+	m.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if ms == nil {
+		return true, fmt.Errorf("still subscribing")
+	}
+	// Only moves change the player
+	if zp != nil && opts.ZonePlayer.UUID() == zp.UUID() {
+		return true, nil
+	}
+
+	ms.cancel()
+	<-ms.done
+	// The old player may have already dropped the subscription
+	_ = m.sonos.Unsubscribe(ctx, opts)
+
+	if zp != nil {
+		opts.ZonePlayer = zp
+		opts.Service = zp.coordinatorService(opts.Service)
+	}
+	sid, err := m.sonos.Subscribe(ctx, opts)
+	if err != nil {
+		// Keep it managed, maintain retries right away
+		m.start(opts, SubscriptionHealth{Options: opts, State: SubscriptionRetrying, Failures: 1, LastError: err})
+		return true, err
+	}
+	m.start(opts, SubscriptionHealth{
+		Options: opts,
+		Sid:     sid,
+		State:   SubscriptionActive,
+		Expires: time.Now().Add(time.Duration(opts.Timeout) * time.Second),
+	})
+	return true, nil
+}
+
+// Unsubscribe stops maintaining the subscription and cancels it on the device.
+func (m *SubscriptionManager) Unsubscribe(ctx context.Context, opts *SubscriptionOptions) error {
+	m.moveMu.Lock()
+	defer m.moveMu.Unlock()
+
+	m.mu.Lock()
+	ms, ok := m.subs[opts]
+	if ms != nil {
+		delete(m.subs, opts)
+	}
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("not subscribed")
+	}
+	if ms == nil {
+		return fmt.Errorf("still subscribing")
+	}
+
+	ms.cancel()
+	<-ms.done
+	return m.sonos.Unsubscribe(ctx, opts)
+}
+
+// Health returns the health of every managed subscription.
+func (m *SubscriptionManager) Health() []SubscriptionHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	health := make([]SubscriptionHealth, 0, len(m.subs))
+	for _, ms := range m.subs {
+		if ms != nil {
+			health = append(health, ms.health)
+		}
+	}
+	return health
+}
+
+// Close stops maintaining the subscriptions and unsubscribes all of them.
+func (m *SubscriptionManager) Close(ctx context.Context) error {
+	m.sonos.managers.Delete(m)
+	m.moveMu.Lock()
+	m.cancel()
+	m.wg.Wait()
+	m.moveMu.Unlock()
+
+	m.mu.Lock()
+	subs := m.subs
+	m.subs = make(map[*SubscriptionOptions]*managedSubscription)
+	m.mu.Unlock()
+
+	var errs []error
+	for opts, ms := range subs {
+		if ms == nil {
+			// Still subscribing, Subscribe backs out as the manager is closed
+			continue
+		}
+		if ms.health.State == SubscriptionRetrying {
+			// The device has most likely dropped it already
+			continue
+		}
+		if err := m.sonos.Unsubscribe(ctx, opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// maintain renews the subscription before it expires and re-establishes it when renewing fails.
+func (m *SubscriptionManager) maintain(ctx context.Context, ms *managedSubscription) {
+	backoff := m.minBackoff
+	wait := m.renewIn(ms.opts)
+	if ms.health.State == SubscriptionRetrying {
+		wait = backoff
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.sonos.closed:
+			return
+		case <-time.After(wait):
+		}
+
+		m.mu.Lock()
+		lost := ms.health.State == SubscriptionRetrying && time.Now().After(ms.health.Expires)
+		m.mu.Unlock()
+
+		var err error
+		if lost {
+			m.sonos.forget(ms.opts.Sid)
+			_, err = m.sonos.Subscribe(ctx, ms.opts)
+		} else {
+			err = m.sonos.Renew(ctx, ms.opts)
+			if errors.Is(err, ErrSubscriptionLost) {
+				// Resubscribe right away, the device won't accept the old SID again
+				m.sonos.forget(ms.opts.Sid)
+				_, err = m.sonos.Subscribe(ctx, ms.opts)
+			}
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			m.sonos.logger.Warn("failed to maintain subscription", "sid", ms.opts.Sid, "retry", backoff, "error", err)
+			m.update(ms, func(h *SubscriptionHealth) {
+				h.State = SubscriptionRetrying
+				h.Failures++
+				h.LastError = err
+			})
+			wait = backoff
+			backoff = min(backoff*2, m.maxBackoff)
+			continue
+		}
+
+		m.update(ms, func(h *SubscriptionHealth) {
+			h.Sid = ms.opts.Sid
+			h.State = SubscriptionActive
+			h.Expires = time.Now().Add(time.Duration(ms.opts.Timeout) * time.Second)
+			h.Failures = 0
+			h.LastError = nil
+		})
+		backoff = m.minBackoff
+		wait = m.renewIn(ms.opts)
+	}
+}
+
+// renewIn returns how long to wait before renewing the subscription.
+func (m *SubscriptionManager) renewIn(opts *SubscriptionOptions) time.Duration {
+	return time.Duration(float64(opts.Timeout) * m.renewFraction * float64(time.Second))
+}
+
+func (m *SubscriptionManager) update(ms *managedSubscription, fn func(*SubscriptionHealth)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(&ms.health)
+}
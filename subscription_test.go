@@ -0,0 +1,142 @@
+package sonos
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitUntil polls cond until it holds or the test times out.
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSubscriptionManager(t *testing.T) {
+	device := newFakeDevice(t, nil)
+	device.timeout = 1
+	zp := device.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	m := NewSubscriptionManager(s, WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+	opts := &SubscriptionOptions{ZonePlayer: zp, Service: zp.AVTransport}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Subscribe(ctx, opts); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if opts.Timeout != 1 {
+		t.Errorf("Expected the granted timeout of 1, got %d", opts.Timeout)
+	}
+	first := opts.Sid
+
+	waitUntil(t, "renewal", func() bool { return device.Renewals() > 0 })
+
+	// The device loses the subscription and answers the next renewal with 412
+	device.Reboot()
+	waitUntil(t, "resubscription", func() bool {
+		health := m.Health()
+		return len(health) == 1 && health[0].State == SubscriptionActive && health[0].Sid != first
+	})
+
+	health := m.Health()[0]
+	if sids := device.Sids(); len(sids) != 1 || sids[0] != health.Sid {
+		t.Errorf("Expected the device to know %s, got %v", health.Sid, sids)
+	}
+
+	if err := m.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if unsubscribed := device.Unsubscribed(); len(unsubscribed) != 1 || unsubscribed[0] != health.Sid {
+		t.Errorf("Expected %s to be unsubscribed, got %v", health.Sid, unsubscribed)
+	}
+	if len(m.Health()) != 0 {
+		t.Errorf("Expected no subscriptions after Close, got %v", m.Health())
+	}
+}
+
+func TestSubscriptionManagerConcurrentSubscribe(t *testing.T) {
+	device := newFakeDevice(t, nil)
+	zp := device.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	m := NewSubscriptionManager(s)
+	opts := &SubscriptionOptions{ZonePlayer: zp, Service: zp.AVTransport}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() { errs <- m.Subscribe(ctx, opts) }()
+	}
+	var failed int
+	for range 2 {
+		if err := <-errs; err != nil {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("Expected exactly one Subscribe to fail, %d did", failed)
+	}
+	if sids := device.Sids(); len(sids) != 1 {
+		t.Errorf("Expected a single subscription on the device, got %v", sids)
+	}
+	if err := m.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestSonosCloseStopsSubscriptionManager(t *testing.T) {
+	device := newFakeDevice(t, nil)
+	device.timeout = 1
+	zp := device.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+
+	m := NewSubscriptionManager(s, WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+	opts := &SubscriptionOptions{ZonePlayer: zp, Service: zp.AVTransport}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Subscribe(ctx, opts); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	s.Close()
+	if sids := device.Sids(); len(sids) != 0 {
+		t.Errorf("Expected no subscriptions after Close, got %v", sids)
+	}
+
+	// Past the renewal of the granted timeout nothing subscribes again
+	time.Sleep(1500 * time.Millisecond)
+	if sids := device.Sids(); len(sids) != 0 {
+		t.Errorf("Expected no subscriptions after Close, got %v", sids)
+	}
+	if renewals := device.Renewals(); renewals != 0 {
+		t.Errorf("Expected no renewals after Close, got %d", renewals)
+	}
+	if len(m.Health()) != 0 {
+		t.Errorf("Expected the manager to be closed, got %v", m.Health())
+	}
+}
@@ -0,0 +1,305 @@
+package sonos
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+// Predicate is a condition on the state of a ZonePlayer, see WaitFor.
+type Predicate struct {
+	// Describes the condition in errors
+	Name string
+	// Service whose events report the observed state
+	Service func(*ZonePlayer) SonosService
+	// Query returns the observed state from the device
+	Query func(*ZonePlayer) (interface{}, error)
+	// Observe returns the observed state carried by an event, if any
+	Observe func(zp *ZonePlayer, evt interface{}) (interface{}, bool)
+	// Match reports whether the observed state satisfies the condition
+	Match func(interface{}) bool
+}
+
+// WaitTimeoutError is returned by WaitFor when the context is done before the condition holds.
+type WaitTimeoutError struct {
+	Predicate string
+	// Last observed state, nil if nothing was observed
+	Last interface{}
+	Err  error
+}
+
+func (e *WaitTimeoutError) Error() string {
+	return fmt.Sprintf("timed out waiting for %s, last observed %v: %v", e.Predicate, e.Last, e.Err)
+}
+
+func (e *WaitTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+type WaitOption func(*waitOptions)
+
+type waitOptions struct {
+	pollInterval time.Duration
+}
+
+// WithPollInterval sets how often the device is queried while there is no subscription to the
+// service of the condition, a second by default.
+func WithPollInterval(d time.Duration) WaitOption {
+	return func(o *waitOptions) {
+		o.pollInterval = d
+	}
+}
+
+// WaitFor blocks until the condition holds or ctx is done. It is driven by the events of the
+// subscriptions to the ZonePlayer and polls the device while there is no subscription to the
+// service of the condition.
+func (z *ZonePlayer) WaitFor(ctx context.Context, p Predicate, opts ...WaitOption) error {
+	o := waitOptions{pollInterval: time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var mu sync.Mutex
+	var last interface{}
+	done := make(chan struct{})
+	var once sync.Once
+	observe := func(v interface{}) {
+		mu.Lock()
+		last = v
+		mu.Unlock()
+		if p.Match(v) {
+			once.Do(func() { close(done) })
+		}
+	}
+
+	// Watch before querying to not miss what changes in between
+	unwatch := z.watch(func(evt interface{}) {
+		if v, ok := p.Observe(z, evt); ok {
+			observe(v)
+		}
+	})
+	defer unwatch()
+
+	query := func() {
+		v, err := p.Query(z)
+		if err != nil {
+			z.logger.Debug("failed to query state", "predicate", p.Name, "error", err)
+			return
+		}
+		observe(v)
+	}
+	query()
+
+	ticker := time.NewTicker(o.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			mu.Lock()
+			defer mu.Unlock()
+			return &WaitTimeoutError{Predicate: p.Name, Last: last, Err: ctx.Err()}
+		case <-ticker.C:
+			if !z.subscribed(p.Service(z)) {
+				query()
+			}
+		}
+	}
+}
+
+// TransportStateIs holds once the transport is in the given state, e.g. PLAYING.
+func TransportStateIs(state avt.TransportStateEnum) Predicate {
+	return Predicate{
+		Name:    fmt.Sprintf("TransportState %s", state),
+		Service: func(zp *ZonePlayer) SonosService { return zp.AVTransport },
+		Query: func(zp *ZonePlayer) (interface{}, error) {
+			res, err := zp.AVTransport.GetTransportInfo(&avt.GetTransportInfoArgs{InstanceID: 0})
+			if err != nil {
+				return nil, err
+			}
+			return res.CurrentTransportState, nil
+		},
+		Observe: func(zp *ZonePlayer, evt interface{}) (interface{}, bool) {
+			v, ok := observeAVTransport(evt, "TransportState")
+			return avt.TransportStateEnum(v), ok
+		},
+		Match: func(v interface{}) bool {
+			return v == state
+		},
+	}
+}
+
+// CurrentTrackURIIs holds once the track with the given URI is the current one.
+func CurrentTrackURIIs(uri string) Predicate {
+	return Predicate{
+		Name:    fmt.Sprintf("CurrentTrackURI %s", uri),
+		Service: func(zp *ZonePlayer) SonosService { return zp.AVTransport },
+		Query: func(zp *ZonePlayer) (interface{}, error) {
+			res, err := zp.GetPositionInfo()
+			if err != nil {
+				return nil, err
+			}
+			return res.TrackURI, nil
+		},
+		Observe: func(zp *ZonePlayer, evt interface{}) (interface{}, bool) {
+			return observeAVTransport(evt, "CurrentTrackURI")
+		},
+		Match: func(v interface{}) bool {
+			return v == uri
+		},
+	}
+}
+
+// VolumeIs holds once the Master volume of the player is the given one.
+func VolumeIs(volume int) Predicate {
+	return Predicate{
+		Name:    fmt.Sprintf("Volume %d", volume),
+		Service: func(zp *ZonePlayer) SonosService { return zp.RenderingControl },
+		Query: func(zp *ZonePlayer) (interface{}, error) {
+			return zp.GetVolume()
+		},
+		Observe: func(zp *ZonePlayer, evt interface{}) (interface{}, bool) {
+			switch e := evt.(type) {
+			case RenderingControlLastChange:
+				v, ok := e.Typed().Volume["Master"]
+				return v, ok
+			case LastChangeEvent:
+				if i, ok := e.Instance("0"); ok && e.Service == "RenderingControl" {
+					if v, ok := i.Get("Volume"); ok {
+						return parseInt(v.Raw), true
+					}
+				}
+			}
+			return nil, false
+		},
+		Match: func(v interface{}) bool {
+			return v == volume
+		},
+	}
+}
+
+// GroupedWith holds once the player is in the same group as every player with the given UUIDs.
+// The observed state is the UUIDs of the members of the group of the player.
+func GroupedWith(uuids ...string) Predicate {
+	return Predicate{
+		Name:    fmt.Sprintf("grouped with %s", strings.Join(uuids, ", ")),
+		Service: func(zp *ZonePlayer) SonosService { return zp.ZoneGroupTopology },
+		Query: func(zp *ZonePlayer) (interface{}, error) {
+			state, err := zp.GetZoneGroupState()
+			if err != nil {
+				return nil, err
+			}
+			return groupMembers(zp, state.ZoneGroups), nil
+		},
+		Observe: func(zp *ZonePlayer, evt interface{}) (interface{}, bool) {
+			if e, ok := evt.(ZoneGroupTopologyZoneGroupState); ok {
+				return groupMembers(zp, e.ZoneGroups.ZoneGroup), true
+			}
+			return nil, false
+		},
+		Match: func(v interface{}) bool {
+			members, _ := v.([]string)
+			for _, uuid := range uuids {
+				if !slices.Contains(members, uuid) {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// groupMembers returns the UUIDs of the members of the group of zp.
+func groupMembers(zp *ZonePlayer, groups []ZoneGroup) []string {
+	for _, group := range groups {
+		var members []string
+		for _, member := range group.ZoneGroupMember {
+			members = append(members, member.UUID)
+		}
+		if slices.Contains(members, zp.UUID()) {
+			return members
+		}
+	}
+	return nil
+}
+
+// observeAVTransport returns the value of the state variable if the event carries it.
+func observeAVTransport(evt interface{}, name string) (string, bool) {
+	switch e := evt.(type) {
+	case AVTransportLastChange:
+		c := e.Typed()
+		if !c.Has(name) {
+			return "", false
+		}
+		switch name {
+		case "TransportState":
+			return string(c.TransportState), true
+		case "CurrentTrackURI":
+			return c.CurrentTrackURI, true
+		case "AVTransportURI":
+			return c.AVTransportURI, true
+		}
+	case LastChangeEvent:
+		if i, ok := e.Instance("0"); ok && e.Service == "AVTransport" {
+			if v, ok := i.Get(name); ok {
+				return v.Raw, true
+			}
+		}
+	}
+	return "", false
+}
+
+// watch calls fn with every event of the subscriptions to the player until the returned
+// function is called.
+func (z *ZonePlayer) watch(fn func(interface{})) func() {
+	w := &fn
+	z.watchMu.Lock()
+	if z.watchers == nil {
+		z.watchers = make(map[*func(interface{})]struct{})
+	}
+	z.watchers[w] = struct{}{}
+	z.watchMu.Unlock()
+
+	return func() {
+		z.watchMu.Lock()
+		delete(z.watchers, w)
+		z.watchMu.Unlock()
+	}
+}
+
+// notifyWatchers passes an event of a subscription to the player to its watchers.
+func (z *ZonePlayer) notifyWatchers(evt interface{}) {
+	z.watchMu.Lock()
+	watchers := make([]func(interface{}), 0, len(z.watchers))
+	for w := range z.watchers {
+		watchers = append(watchers, *w)
+	}
+	z.watchMu.Unlock()
+
+	for _, fn := range watchers {
+		fn(evt)
+	}
+}
+
+// subscribed reports whether there is a subscription to the service of the player.
+func (z *ZonePlayer) subscribed(service SonosService) bool {
+	z.watchMu.Lock()
+	defer z.watchMu.Unlock()
+	return z.subscriptions[service.EventEndpoint().Path] > 0
+}
+
+// trackSubscription records a subscription to the service of the player being added or removed.
+func (z *ZonePlayer) trackSubscription(service SonosService, delta int) {
+	z.watchMu.Lock()
+	defer z.watchMu.Unlock()
+	if z.subscriptions == nil {
+		z.subscriptions = make(map[string]int)
+	}
+	z.subscriptions[service.EventEndpoint().Path] += delta
+}
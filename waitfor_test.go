@@ -0,0 +1,88 @@
+package sonos
+
+import (
+	"context"
+	"errors"
+	"html"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
+)
+
+func TestWaitForEvent(t *testing.T) {
+	device := newFakeDevice(t, map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:AVTransport:1#GetTransportInfo": mockResponseHandler("AVTransport", "GetTransportInfo", "<CurrentTransportState>TRANSITIONING</CurrentTransportState>"),
+	})
+	zp := device.ZonePlayer(t)
+
+	s, err := NewSonos()
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sid, err := s.Subscribe(ctx, &SubscriptionOptions{ZonePlayer: zp, Service: zp.AVTransport})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		// Polling would never see PLAYING
+		done <- zp.WaitFor(ctx, TransportStateIs(avt.TransportState_PLAYING), WithPollInterval(10*time.Millisecond))
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	status, err := device.Notify(sid, lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/></InstanceID></Event>`))
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Notify failed: %d %v", status, err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("WaitFor failed: %v", err)
+	}
+}
+
+func TestWaitForPolling(t *testing.T) {
+	var calls atomic.Int32
+	zp := NewMockZonePlayer(t, map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:RenderingControl:1#GetVolume": func(req *http.Request) (*http.Response, error) {
+			volume := "10"
+			if calls.Add(1) > 2 {
+				volume = "30"
+			}
+			return mockResponseHandler("RenderingControl", "GetVolume", "<CurrentVolume>"+volume+"</CurrentVolume>")(req)
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := zp.WaitFor(ctx, VolumeIs(30), WithPollInterval(10*time.Millisecond)); err != nil {
+		t.Fatalf("WaitFor failed: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 queries, got %d", calls.Load())
+	}
+}
+
+func TestWaitForTimeout(t *testing.T) {
+	zp := NewMockZonePlayer(t, map[string]func(*http.Request) (*http.Response, error){
+		"urn:schemas-upnp-org:service:ZoneGroupTopology:1#GetZoneGroupState": mockResponseHandler("ZoneGroupTopology", "GetZoneGroupState", "<ZoneGroupState>"+html.EscapeString(`<ZoneGroupState><ZoneGroups><ZoneGroup Coordinator="RINCON_000E58CDCA4001400" ID="RINCON_000E58CDCA4001400:1"><ZoneGroupMember UUID="RINCON_000E58CDCA4001400"/></ZoneGroup><ZoneGroup Coordinator="RINCON_BBB" ID="RINCON_BBB:1"><ZoneGroupMember UUID="RINCON_BBB"/></ZoneGroup></ZoneGroups></ZoneGroupState>`)+"</ZoneGroupState>"),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := zp.WaitFor(ctx, GroupedWith("RINCON_BBB"), WithPollInterval(10*time.Millisecond))
+
+	var timeout *WaitTimeoutError
+	if !errors.As(err, &timeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a WaitTimeoutError, got %v", err)
+	}
+	if members, _ := timeout.Last.([]string); len(members) != 1 || members[0] != "RINCON_000E58CDCA4001400" {
+		t.Errorf("Expected the last observed members, got %v", timeout.Last)
+	}
+}
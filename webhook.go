@@ -0,0 +1,506 @@
+package sonos
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the body of a webhook request, as
+// "sha256=<hex>", when the webhook has a secret.
+const SignatureHeader = "X-Sonos-Signature"
+
+// Webhook is an URL the events are POSTed to as a JSON array of JournalEntry.
+type Webhook struct {
+	URL string
+	// Key of the HMAC signature, requests aren't signed without one
+	Secret string
+	// Names or UUIDs of the rooms to forward the events of, all rooms if empty
+	Rooms []string
+	// Types of the events to forward, e.g. sonos.AVTransportLastChange or AVTransportLastChange,
+	// all types if empty
+	Types []string
+}
+
+func (w *Webhook) matches(e *JournalEntry) bool {
+	if len(w.Rooms) > 0 && !slices.Contains(w.Rooms, e.Room) && !slices.Contains(w.Rooms, e.UUID) {
+		return false
+	}
+	if len(w.Types) > 0 {
+		name := e.Type[strings.LastIndex(e.Type, ".")+1:]
+		if !slices.Contains(w.Types, e.Type) && !slices.Contains(w.Types, name) {
+			return false
+		}
+	}
+	return true
+}
+
+type ForwarderOption func(*forwarderOptions)
+
+type forwarderOptions struct {
+	dir           string
+	queueSize     int
+	batchSize     int
+	batchInterval time.Duration
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	client        *http.Client
+}
+
+// WithQueueDir keeps the undelivered events in dir, so they survive restarts. They are only
+// kept in memory by default.
+func WithQueueDir(dir string) ForwarderOption {
+	return func(o *forwarderOptions) {
+		o.dir = dir
+	}
+}
+
+// WithQueueSize sets how many undelivered events are kept per webhook before the oldest ones
+// are dropped, 1000 by default.
+func WithQueueSize(n int) ForwarderOption {
+	return func(o *forwarderOptions) {
+		o.queueSize = n
+	}
+}
+
+// WithBatch sets how many events are sent at most per request and how long the first event
+// waits for others to join its batch, 20 and 1 second by default.
+func WithBatch(size int, interval time.Duration) ForwarderOption {
+	return func(o *forwarderOptions) {
+		o.batchSize = size
+		o.batchInterval = interval
+	}
+}
+
+// WithRetryBackoff sets the bounds of the exponential backoff between failed deliveries, 1 second
+// and 1 minute by default.
+func WithRetryBackoff(initial, limit time.Duration) ForwarderOption {
+	return func(o *forwarderOptions) {
+		o.minBackoff = initial
+		o.maxBackoff = limit
+	}
+}
+
+// WithWebhookClient sets the client the webhooks are requested with.
+func WithWebhookClient(c *http.Client) ForwarderOption {
+	return func(o *forwarderOptions) {
+		o.client = c
+	}
+}
+
+// Forwarder POSTs the decoded events to webhooks. It delivers the events of the Sonos it is
+// passed to with WithForwarder.
+type Forwarder struct {
+	opts   forwarderOptions
+	hooks  []*webhook
+	logger *slog.Logger
+
+	startOnce sync.Once
+	stop      chan struct{}
+	stopOnce  sync.Once
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+type webhook struct {
+	Webhook
+	queue *webhookQueue
+	wake  chan struct{}
+}
+
+func NewForwarder(webhooks []Webhook, opts ...ForwarderOption) (*Forwarder, error) {
+	o := forwarderOptions{
+		queueSize:     1000,
+		batchSize:     20,
+		batchInterval: time.Second,
+		minBackoff:    time.Second,
+		maxBackoff:    time.Minute,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	f := &Forwarder{
+		opts:   o,
+		logger: slog.Default(),
+		stop:   make(chan struct{}),
+	}
+	f.ctx, f.cancel = context.WithCancel(context.Background())
+
+	for _, w := range webhooks {
+		q := &webhookQueue{limit: o.queueSize}
+		if o.dir != "" {
+			sum := sha256.Sum256([]byte(w.URL))
+			q.path = filepath.Join(o.dir, hex.EncodeToString(sum[:8])+".jsonl")
+			if err := q.load(); err != nil {
+				return nil, fmt.Errorf("%s: %w", w.URL, err)
+			}
+		}
+		f.hooks = append(f.hooks, &webhook{Webhook: w, queue: q, wake: make(chan struct{}, 1)})
+	}
+	return f, nil
+}
+
+// Close delivers the queued events until ctx expires and stops the forwarder. Undelivered
+// events stay in the queue directory, if there is one.
+func (f *Forwarder) Close(ctx context.Context) error {
+	f.stopOnce.Do(func() { close(f.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		f.cancel()
+		<-done
+	}
+	f.cancel()
+
+	var undelivered int
+	for _, w := range f.hooks {
+		if err := w.queue.close(); err != nil {
+			f.logger.Warn("failed to persist webhook queue", "url", w.URL, "error", err)
+		}
+		undelivered += w.queue.len()
+	}
+	if undelivered > 0 {
+		return fmt.Errorf("%d events undelivered", undelivered)
+	}
+	return nil
+}
+
+func (f *Forwarder) start(logger *slog.Logger) {
+	f.startOnce.Do(func() {
+		f.logger = logger
+		for _, w := range f.hooks {
+			f.wg.Add(1)
+			go f.loop(w)
+		}
+	})
+}
+
+func (f *Forwarder) forward(entry JournalEntry) {
+	select {
+	case <-f.stop:
+		return
+	default:
+	}
+	for _, w := range f.hooks {
+		if !w.matches(&entry) {
+			continue
+		}
+		dropped, err := w.queue.push(entry)
+		if err != nil {
+			f.logger.Warn("failed to persist webhook queue", "url", w.URL, "error", err)
+		}
+		if dropped > 0 {
+			f.logger.Warn("webhook queue full, dropped oldest events", "url", w.URL, "dropped", dropped)
+		}
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (f *Forwarder) loop(w *webhook) {
+	defer f.wg.Done()
+	for {
+		if w.queue.len() == 0 {
+			select {
+			case <-f.stop:
+				return
+			case <-w.wake:
+			}
+		}
+
+		// Give the events following the first one the chance to join its batch
+		timer := time.NewTimer(f.opts.batchInterval)
+	collect:
+		for w.queue.len() < f.opts.batchSize {
+			select {
+			case <-f.stop:
+				break collect
+			case <-timer.C:
+				break collect
+			case <-w.wake:
+			}
+		}
+		timer.Stop()
+
+		if !f.drain(w) {
+			return
+		}
+	}
+}
+
+// drain delivers the queued events, backing off while the webhook fails. It returns false once
+// the forwarder is stopped.
+func (f *Forwarder) drain(w *webhook) bool {
+	backoff := f.opts.minBackoff
+	for {
+		batch := w.queue.peek(f.opts.batchSize)
+		if len(batch) == 0 {
+			return true
+		}
+
+		err := f.send(w, batch)
+		var permanent *webhookError
+		switch {
+		case err == nil:
+		case errors.As(err, &permanent) && permanent.permanent():
+			f.logger.Warn("webhook rejected events, dropping them", "url", w.URL, "events", len(batch), "error", err)
+		default:
+			f.logger.Warn("failed to deliver events", "url", w.URL, "events", len(batch), "error", err)
+			// Drop what overflowed the queue from the file meanwhile
+			if err := w.queue.compact(); err != nil {
+				f.logger.Warn("failed to persist webhook queue", "url", w.URL, "error", err)
+			}
+			select {
+			case <-f.stop:
+				// One attempt per batch while stopping
+				return false
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, f.opts.maxBackoff)
+			continue
+		}
+
+		if err := w.queue.pop(len(batch)); err != nil {
+			f.logger.Warn("failed to persist webhook queue", "url", w.URL, "error", err)
+		}
+		backoff = f.opts.minBackoff
+	}
+}
+
+type webhookError struct {
+	status int
+}
+
+func (e *webhookError) Error() string {
+	return fmt.Sprintf("webhook responded with %d %s", e.status, http.StatusText(e.status))
+}
+
+// permanent reports whether retrying the request won't help.
+func (e *webhookError) permanent() bool {
+	return e.status >= 400 && e.status < 500 && e.status != http.StatusRequestTimeout && e.status != http.StatusTooManyRequests
+}
+
+func (f *Forwarder) send(w *webhook, batch []JournalEntry) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(f.ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign([]byte(w.Secret), body))
+	}
+
+	resp, err := f.opts.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &webhookError{status: resp.StatusCode}
+	}
+	return nil
+}
+
+// Sign returns the value of SignatureHeader for the body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookQueue holds the undelivered events of a webhook, mirrored to a file if it has a path.
+// Pushed events are appended to the file, which the sender compacts once events were delivered
+// or dropped, keeping the rewrites off the event delivery.
+type webhookQueue struct {
+	mu      sync.Mutex
+	path    string
+	limit   int
+	entries []JournalEntry
+	// Events pushed so far, telling which ones a compaction missed
+	pushed int
+	// Lines of the file which aren't queued anymore
+	stale int
+	// Opened for appending by the first push after loading or compacting
+	file *os.File
+}
+
+func (q *webhookQueue) load() error {
+	f, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		var entry JournalEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		q.entries = append(q.entries, entry)
+	}
+	if len(q.entries) > q.limit {
+		q.stale = len(q.entries) - q.limit
+		q.entries = q.entries[q.stale:]
+	}
+	return nil
+}
+
+// push appends the entry and returns how many of the oldest entries were dropped for it.
+func (q *webhookQueue) push(entry JournalEntry) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.entries = append(q.entries, entry)
+	q.pushed++
+	var dropped int
+	if len(q.entries) > q.limit {
+		dropped = len(q.entries) - q.limit
+		q.entries = slices.Delete(q.entries, 0, dropped)
+		q.stale += dropped
+	}
+	return dropped, q.append(entry)
+}
+
+func (q *webhookQueue) peek(n int) []JournalEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.entries[:min(n, len(q.entries))])
+}
+
+// pop removes the delivered entries and compacts the file.
+func (q *webhookQueue) pop(n int) error {
+	q.mu.Lock()
+	n = min(n, len(q.entries))
+	q.entries = slices.Delete(q.entries, 0, n)
+	q.stale += n
+	q.mu.Unlock()
+	return q.compact()
+}
+
+func (q *webhookQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// append writes the entries to the end of the file.
+func (q *webhookQueue) append(entries ...JournalEntry) error {
+	if q.path == "" || len(entries) == 0 {
+		return nil
+	}
+	if q.file == nil {
+		f, err := os.OpenFile(q.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		q.file = f
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	_, err := q.file.Write(b.Bytes())
+	return err
+}
+
+// compact rewrites the file with the queued entries if it has stale lines. The entries are
+// written without holding the lock, the ones pushed meanwhile are added before replacing the
+// file. Only the sender compacts.
+func (q *webhookQueue) compact() error {
+	q.mu.Lock()
+	if q.path == "" || q.stale == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	entries, pushed, stale := slices.Clone(q.entries), q.pushed, q.stale
+	q.mu.Unlock()
+
+	tmp := q.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n := min(q.pushed-pushed, len(q.entries)); n > 0 {
+		for _, entry := range q.entries[len(q.entries)-n:] {
+			if err := enc.Encode(entry); err != nil {
+				f.Close()
+				return err
+			}
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return err
+	}
+	// The next push appends to the new file
+	if q.file != nil {
+		q.file.Close()
+		q.file = nil
+	}
+	q.stale -= stale
+	return nil
+}
+
+// close compacts and closes the file.
+func (q *webhookQueue) close() error {
+	err := q.compact()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file != nil {
+		err = errors.Join(err, q.file.Close())
+		q.file = nil
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+package sonos
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// webhookReceiver collects the batches POSTed to it.
+type webhookReceiver struct {
+	*httptest.Server
+	// Status to respond with, 200 if zero
+	status atomic.Int32
+
+	mu      sync.Mutex
+	batches [][]JournalEntry
+	headers []http.Header
+}
+
+func newWebhookReceiver(t *testing.T) *webhookReceiver {
+	r := &webhookReceiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if status := r.status.Load(); status != 0 {
+			w.WriteHeader(int(status))
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		var batch []JournalEntry
+		if err := json.Unmarshal(body, &batch); err != nil {
+			t.Errorf("Unmarshal failed: %v", err)
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.batches = append(r.batches, batch)
+		r.headers = append(r.headers, req.Header.Clone())
+		// Check the signature against the body as sent
+		r.headers[len(r.headers)-1].Set("X-Body-Signature", Sign([]byte("secret"), body))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *webhookReceiver) Entries() []JournalEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var entries []JournalEntry
+	for _, batch := range r.batches {
+		entries = append(entries, batch...)
+	}
+	return entries
+}
+
+func (r *webhookReceiver) waitFor(t *testing.T, n int) []JournalEntry {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if entries := r.Entries(); len(entries) >= n {
+			return entries
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d entries, got %v", n, r.Entries())
+	return nil
+}
+
+func TestForwarder(t *testing.T) {
+	device := newFakeDevice(t, nil)
+	device.initialEvents = map[string]string{
+		"/MediaRenderer/AVTransport/Event":           lastChangeEvent(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/></InstanceID></Event>`),
+		"/MediaRenderer/GroupRenderingControl/Event": `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><GroupVolume>30</GroupVolume></e:property></e:propertyset>`,
+	}
+	zp := device.ZonePlayer(t)
+
+	all := newWebhookReceiver(t)
+	transport := newWebhookReceiver(t)
+	elsewhere := newWebhookReceiver(t)
+	f, err := NewForwarder([]Webhook{
+		{URL: all.URL},
+		{URL: transport.URL, Secret: "secret", Rooms: []string{"Kitchen"}, Types: []string{"AVTransportLastChange"}},
+		{URL: elsewhere.URL, Rooms: []string{"Office"}},
+	}, WithBatch(10, 50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewForwarder failed: %v", err)
+	}
+
+	s, err := NewSonos(WithForwarder(f))
+	if err != nil {
+		t.Fatalf("NewSonos failed: %v", err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, service := range []SonosService{zp.AVTransport, zp.GroupRenderingControl} {
+		if _, err := s.Subscribe(ctx, &SubscriptionOptions{ZonePlayer: zp, Service: service}); err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+	}
+
+	if err := f.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	entries := all.Entries()
+	if len(all.batches) != 1 || len(entries) != 2 || entries[0].Type != "sonos.AVTransportLastChange" || entries[1].Type != "grouprenderingcontrol.GroupVolume" {
+		t.Errorf("Expected both events in one batch, got %v", all.batches)
+	}
+	if all.headers[0].Get(SignatureHeader) != "" {
+		t.Error("Expected an unsigned request without a secret")
+	}
+
+	entries = transport.Entries()
+	if len(entries) != 1 || entries[0].Room != "Kitchen" || entries[0].Type != "sonos.AVTransportLastChange" {
+		t.Errorf("Expected the AVTransport event, got %v", entries)
+	}
+	if h := transport.headers[0]; h.Get(SignatureHeader) == "" || h.Get(SignatureHeader) != h.Get("X-Body-Signature") {
+		t.Errorf("Unexpected signature %q, expected %q", h.Get(SignatureHeader), h.Get("X-Body-Signature"))
+	}
+	evt, err := entries[0].Event()
+	if e, ok := evt.(AVTransportLastChange); err != nil || !ok || e.InstanceID.TransportState.Value != "PLAYING" {
+		t.Errorf("Unexpected event %#v: %v", evt, err)
+	}
+
+	if entries := elsewhere.Entries(); len(entries) != 0 {
+		t.Errorf("Expected no events for another room, got %v", entries)
+	}
+}
+
+func TestForwarderRetry(t *testing.T) {
+	r := newWebhookReceiver(t)
+	r.status.Store(http.StatusServiceUnavailable)
+	dir := t.TempDir()
+
+	entry := func(seq string) JournalEntry {
+		return JournalEntry{Room: "Kitchen", Seq: seq, Type: "grouprenderingcontrol.GroupVolume", Payload: json.RawMessage(seq)}
+	}
+
+	f, err := NewForwarder([]Webhook{{URL: r.URL}}, WithQueueDir(dir), WithQueueSize(2), WithBatch(10, time.Millisecond), WithRetryBackoff(time.Millisecond, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewForwarder failed: %v", err)
+	}
+	f.start(slog.Default())
+	for _, seq := range []string{"1", "2", "3"} {
+		f.forward(entry(seq))
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := f.Close(ctx); err == nil {
+		t.Fatal("Expected the events to be undelivered")
+	}
+
+	// The queue survives a restart, minus the oldest event which didn't fit
+	r.status.Store(0)
+	f, err = NewForwarder([]Webhook{{URL: r.URL}}, WithQueueDir(dir), WithBatch(10, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewForwarder failed: %v", err)
+	}
+	f.start(slog.Default())
+	entries := r.waitFor(t, 2)
+	if len(entries) != 2 || entries[0].Seq != "2" || entries[1].Seq != "3" {
+		t.Errorf("Expected the two latest events, got %v", entries)
+	}
+	if err := f.Close(context.Background()); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
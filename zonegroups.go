@@ -60,6 +60,7 @@ type Satellite struct {
 	AirPlayEnabled          string   `xml:"AirPlayEnabled"`
 	IdleState               string   `xml:"IdleState"`
 	MoreInfo                string   `xml:"MoreInfo"`
+	HTSatChanMapSet         string   `xml:"HTSatChanMapSet,attr"`
 }
 
 type ZoneGroupMember struct {
@@ -90,6 +91,9 @@ type ZoneGroupMember struct {
 	AirPlayEnabled          string           `xml:"AirPlayEnabled"`
 	IdleState               string           `xml:"IdleState"`
 	MoreInfo                string           `xml:"MoreInfo"`
+	Invisible               string           `xml:"Invisible,attr"`
+	ChannelMapSet           string           `xml:"ChannelMapSet,attr"`
+	HTSatChanMapSet         string           `xml:"HTSatChanMapSet,attr"`
 	Satellite               []Satellite      `xml:"Satellite"`
 	VanishedDevice          []VanishedDevice `xml:"VanishedDevices>VanishedDevice"`
 }
@@ -105,3 +109,16 @@ type ZoneGroupState struct {
 	XMLName    xml.Name    `xml:"ZoneGroupState"`
 	ZoneGroups []ZoneGroup `xml:"ZoneGroups>ZoneGroup"`
 }
+
+// VisibleMembers returns the members of the group which can be controlled on their own,
+// leaving out the invisible halves of stereo pairs and bonded players.
+func (g *ZoneGroup) VisibleMembers() []ZoneGroupMember {
+	var members []ZoneGroupMember
+	for _, member := range g.ZoneGroupMember {
+		if member.Invisible == "1" {
+			continue
+		}
+		members = append(members, member)
+	}
+	return members
+}
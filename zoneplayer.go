@@ -4,9 +4,11 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/caglar10ur/sonos/didl"
@@ -122,6 +124,13 @@ func WithLocation(u *url.URL) ZonePlayerOption {
 	}
 }
 
+// WithZonePlayerLogger sets the logger used to report problems, slog.Default() by default.
+func WithZonePlayerLogger(logger *slog.Logger) ZonePlayerOption {
+	return func(z *ZonePlayer) {
+		z.logger = logger
+	}
+}
+
 func FromEndpoint(endpoint string) (*url.URL, error) {
 	return url.Parse(fmt.Sprintf("http://%s:1400/xml/device_description.xml", endpoint))
 }
@@ -137,6 +146,13 @@ type ZonePlayer struct {
 	// A URL that can be queried for device capabilities
 	location *url.URL
 
+	logger *slog.Logger
+
+	watchMu  sync.Mutex
+	watchers map[*func(interface{})]struct{}
+	// Number of subscriptions keyed by the event path of the service
+	subscriptions map[string]int
+
 	*Services
 }
 
@@ -166,6 +182,7 @@ func NewZonePlayer(opts ...ZonePlayerOption) (*ZonePlayer, error) {
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		logger: slog.Default(),
 	}
 
 	// Loop through each option
@@ -277,6 +294,11 @@ func (z *ZonePlayer) ModelName() string {
 	return z.Root.Device.ModelName
 }
 
+// ModelNumber returns the model number of the device, e.g. S13 for a Sonos One.
+func (z *ZonePlayer) ModelNumber() string {
+	return z.Root.Device.ModelNumber
+}
+
 func (z *ZonePlayer) HardwareVersion() string {
 	return z.Root.Device.HardwareVersion
 }
@@ -301,6 +323,19 @@ func (z *ZonePlayer) UUID() string {
 	return strings.Split(z.Root.Device.UDN, ":")[1]
 }
 
+// zonePlayerAt returns a ZonePlayer for another player in the household sharing the same http client.
+func (z *ZonePlayer) zonePlayerAt(uuid, location string) (*ZonePlayer, error) {
+	if uuid == z.UUID() {
+		return z, nil
+	}
+
+	u, err := FromLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	return NewZonePlayer(WithLocation(u), WithClient(z.client), WithZonePlayerLogger(z.logger))
+}
+
 func (z *ZonePlayer) IsCoordinator() bool {
 	zoneGroupState, err := z.GetZoneGroupState()
 	if err != nil {
@@ -488,6 +523,7 @@ func (z *ZonePlayer) SetAVTransportURI(url string) error {
 //   - RenderingControl (LastChange)
 //   - Queue (LastChange)
 //   - ZoneGroupTopology (AvailableSoftwareUpdate, ZoneGroupState, etc.)
+//   - LastChange of other services as LastChangeEvent
 //   - AlarmClock
 //   - AudioIn
 //   - ConnectionManager
@@ -504,7 +540,8 @@ func (zp *ZonePlayer) Event(evt interface{}, fn EventHandlerFunc) {
 		var levt AVTransportLastChange
 		err := xml.Unmarshal([]byte(e), &levt)
 		if err != nil {
-			fmt.Printf("Unmarshal failure: %s", err)
+			zp.logger.Warn("failed to unmarshal event", "event", fmt.Sprintf("%T", e), "error", err)
+			fn(newRawEvent(e))
 			return
 		}
 		fn(levt)
@@ -512,7 +549,8 @@ func (zp *ZonePlayer) Event(evt interface{}, fn EventHandlerFunc) {
 		var levt RenderingControlLastChange
 		err := xml.Unmarshal([]byte(e), &levt)
 		if err != nil {
-			fmt.Printf("Unmarshal failure: %s", err)
+			zp.logger.Warn("failed to unmarshal event", "event", fmt.Sprintf("%T", e), "error", err)
+			fn(newRawEvent(e))
 			return
 		}
 		fn(levt)
@@ -521,7 +559,8 @@ func (zp *ZonePlayer) Event(evt interface{}, fn EventHandlerFunc) {
 		var levt QueueLastChange
 		err := xml.Unmarshal([]byte(e), &levt)
 		if err != nil {
-			fmt.Printf("Unmarshal failure: %s", err)
+			zp.logger.Warn("failed to unmarshal event", "event", fmt.Sprintf("%T", e), "error", err)
+			fn(newRawEvent(e))
 			return
 		}
 		fn(levt)
@@ -529,7 +568,8 @@ func (zp *ZonePlayer) Event(evt interface{}, fn EventHandlerFunc) {
 		var levt ZoneGroupTopologyAvailableSoftwareUpdate
 		err := xml.Unmarshal([]byte(e), &levt)
 		if err != nil {
-			fmt.Printf("Unmarshal failure: %s", err)
+			zp.logger.Warn("failed to unmarshal event", "event", fmt.Sprintf("%T", e), "error", err)
+			fn(newRawEvent(e))
 			return
 		}
 		fn(levt)
@@ -537,7 +577,8 @@ func (zp *ZonePlayer) Event(evt interface{}, fn EventHandlerFunc) {
 		var levt ZoneGroupTopologyZoneGroupState
 		err := xml.Unmarshal([]byte(e), &levt)
 		if err != nil {
-			fmt.Printf("Unmarshal failure: %s", err)
+			zp.logger.Warn("failed to unmarshal event", "event", fmt.Sprintf("%T", e), "error", err)
+			fn(newRawEvent(e))
 			return
 		}
 		fn(levt)
@@ -721,6 +762,10 @@ func (zp *ZonePlayer) Event(evt interface{}, fn EventHandlerFunc) {
 	case zgt.NetsettingsUpdateID:
 		fn(e)
 	default:
-		fmt.Printf("Unhandeld event %T: %s\n", e, e)
+		if isLastChange(e) {
+			zp.lastChangeEvent(e, fn)
+			return
+		}
+		fn(newRawEvent(e))
 	}
 }
@@ -3,8 +3,13 @@ package sonos
 import (
 	"bytes"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
+
+	avt "github.com/caglar10ur/sonos/services/AVTransport"
 )
 
 // Helper to wrap body in SOAP envelope
@@ -406,3 +411,29 @@ func TestInputOutput(t *testing.T) {
 		t.Fatalf("SetAVTransportURI failed: %v", err)
 	}
 }
+
+func TestEventRawEvent(t *testing.T) {
+	var logs bytes.Buffer
+	mockClient := &http.Client{Transport: &MockRoundTripper{}}
+	loc, _ := url.Parse("http://192.168.1.100:1400/xml/device_description.xml")
+	zp, err := NewZonePlayer(WithClient(mockClient), WithLocation(loc), WithZonePlayerLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	if err != nil {
+		t.Fatalf("Failed to create ZonePlayer: %v", err)
+	}
+
+	var events []interface{}
+	zp.Event(avt.LastChange("<Event"), func(evt interface{}) {
+		events = append(events, evt)
+	})
+
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	raw, ok := events[0].(RawEvent)
+	if !ok || raw.Service != "AVTransport" || raw.Name != "LastChange" || raw.Value != "<Event" {
+		t.Errorf("Unexpected event: %+v", events[0])
+	}
+	if !strings.Contains(logs.String(), "failed to unmarshal event") {
+		t.Errorf("Expected the failure to be logged, got %s", logs.String())
+	}
+}